@@ -0,0 +1,267 @@
+// Package charset converts text between UTF-8 and the other
+// encodings supported by Hawk: UTF-16 and ISO-8859-1 (Latin-1).
+package charset
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+type encoding int
+
+const (
+	utf8Enc  encoding = iota
+	utf16Enc          // byte order given by BOM, big endian by default
+	utf16LE
+	utf16BE
+	latin1
+)
+
+func lookup(name string) (encoding, error) {
+	switch strings.ToLower(name) {
+	case "", "utf8", "utf-8":
+		return utf8Enc, nil
+	case "utf16", "utf-16":
+		return utf16Enc, nil
+	case "utf16le", "utf-16le":
+		return utf16LE, nil
+	case "utf16be", "utf-16be":
+		return utf16BE, nil
+	case "latin1", "latin-1", "iso-8859-1", "iso8859-1":
+		return latin1, nil
+	}
+	return 0, fmt.Errorf("unsupported encoding: %s", name)
+}
+
+// Check returns an error if the encoding name is not supported.
+func Check(name string) error {
+	_, err := lookup(name)
+	return err
+}
+
+// NewDecoder returns a reader that decodes r from the encoding
+// name to UTF-8. For UTF-16, a byte order mark at the beginning
+// of r is removed. With no explicit byte order, it determines the
+// byte order; otherwise, it is removed only if it matches it.
+func NewDecoder(r io.Reader, name string) (io.Reader, error) {
+	enc, err := lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	d := &decoder{r: r}
+	switch enc {
+	case utf8Enc:
+		return r, nil
+	case utf16Enc:
+		d.bom = true
+		d.decode = d.decodeUTF16
+	case utf16LE:
+		d.bom = true
+		d.order = binary.LittleEndian
+		d.decode = d.decodeUTF16
+	case utf16BE:
+		d.bom = true
+		d.order = binary.BigEndian
+		d.decode = d.decodeUTF16
+	case latin1:
+		d.decode = d.decodeLatin1
+	}
+	return d, nil
+}
+
+type decoder struct {
+	r     io.Reader
+	buf   [4096]byte
+	in    []byte // input not decoded yet
+	out   []byte // decoded output not read yet
+	err   error
+	order binary.ByteOrder
+	bom   bool // the input may start with a BOM
+
+	// decode decodes d.in into d.out. If final is true,
+	// there is no more input to wait for.
+	decode func(final bool)
+}
+
+func (d *decoder) Read(p []byte) (n int, err error) {
+	for len(d.out) == 0 {
+		if d.err != nil {
+			return 0, d.err
+		}
+		n, err := d.r.Read(d.buf[:])
+		d.in = append(d.in, d.buf[:n]...)
+		d.err = err
+		d.decode(err != nil)
+	}
+	n = copy(p, d.out)
+	d.out = d.out[n:]
+	return n, nil
+}
+
+func (d *decoder) decodeLatin1(final bool) {
+	for _, b := range d.in {
+		d.out = appendRune(d.out, rune(b))
+	}
+	d.in = d.in[:0]
+}
+
+func (d *decoder) decodeUTF16(final bool) {
+	in := d.in
+	if d.bom {
+		if len(in) < 2 && !final {
+			return
+		}
+		d.bom = false
+		if len(in) >= 2 {
+			switch {
+			case in[0] == 0xff && in[1] == 0xfe && d.order != binary.BigEndian:
+				d.order = binary.LittleEndian
+				in = in[2:]
+			case in[0] == 0xfe && in[1] == 0xff && d.order != binary.LittleEndian:
+				d.order = binary.BigEndian
+				in = in[2:]
+			}
+		}
+		if d.order == nil {
+			d.order = binary.BigEndian
+		}
+	}
+	for len(in) >= 2 {
+		r := rune(d.order.Uint16(in))
+		if utf16.IsSurrogate(r) {
+			if len(in) < 4 {
+				if !final {
+					break
+				}
+				r = utf8.RuneError
+			} else {
+				r = utf16.DecodeRune(r, rune(d.order.Uint16(in[2:])))
+				if r == utf8.RuneError {
+					in = in[2:] // unpaired surrogate
+				} else {
+					in = in[4:]
+				}
+				d.out = appendRune(d.out, r)
+				continue
+			}
+		}
+		d.out = appendRune(d.out, r)
+		in = in[2:]
+	}
+	if final && len(in) > 0 {
+		d.out = appendRune(d.out, utf8.RuneError)
+		in = nil
+	}
+	d.in = append(d.in[:0], in...)
+}
+
+func appendRune(b []byte, r rune) []byte {
+	var buf [utf8.UTFMax]byte
+	n := utf8.EncodeRune(buf[:], r)
+	return append(b, buf[:n]...)
+}
+
+// NewEncoder returns a writer that encodes UTF-8 text written
+// to it in the encoding name and writes it to w. Runes that
+// cannot be represented in Latin-1 are written as '?'. For
+// UTF-16 with no explicit byte order, a little endian byte
+// order mark is written first. Close must be called to write
+// an incomplete UTF-8 sequence at the end of the text, which is
+// encoded as U+FFFD; it doesn't close w.
+func NewEncoder(w io.Writer, name string) (io.WriteCloser, error) {
+	enc, err := lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	e := &encoder{w: w}
+	switch enc {
+	case utf8Enc:
+		return nopCloser{w}, nil
+	case utf16Enc:
+		e.bom = true
+		fallthrough
+	case utf16LE:
+		e.encode = encodeUTF16(binary.LittleEndian)
+	case utf16BE:
+		e.encode = encodeUTF16(binary.BigEndian)
+	case latin1:
+		e.encode = encodeLatin1
+	}
+	return e, nil
+}
+
+type encoder struct {
+	w      io.Writer
+	rest   []byte // incomplete UTF-8 sequence from the last Write
+	bom    bool   // BOM not written yet
+	encode func(b []byte, r rune) []byte
+}
+
+func (e *encoder) Write(p []byte) (n int, err error) {
+	in := p
+	if len(e.rest) > 0 {
+		in = append(e.rest, p...)
+		e.rest = nil
+	}
+	var out []byte
+	if e.bom {
+		out = e.encode(out, '\uFEFF')
+		e.bom = false
+	}
+	for len(in) > 0 {
+		if !utf8.FullRune(in) {
+			e.rest = append([]byte(nil), in...)
+			break
+		}
+		r, size := utf8.DecodeRune(in)
+		out = e.encode(out, r)
+		in = in[size:]
+	}
+	if _, err := e.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close writes the incomplete UTF-8 sequence left
+// by the last Write.
+func (e *encoder) Close() error {
+	var out []byte
+	for in := e.rest; len(in) > 0; in = in[1:] {
+		out = e.encode(out, utf8.RuneError)
+	}
+	e.rest = nil
+	if len(out) > 0 {
+		_, err := e.w.Write(out)
+		return err
+	}
+	return nil
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+func encodeLatin1(b []byte, r rune) []byte {
+	if r > 0xff {
+		r = '?'
+	}
+	return append(b, byte(r))
+}
+
+func encodeUTF16(order binary.ByteOrder) func(b []byte, r rune) []byte {
+	return func(b []byte, r rune) []byte {
+		var buf [2]byte
+		for _, u := range utf16.Encode([]rune{r}) {
+			order.PutUint16(buf[:], u)
+			b = append(b, buf[:]...)
+		}
+		return b
+	}
+}
@@ -0,0 +1,108 @@
+package charset
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+	"testing/iotest"
+)
+
+var decodeTests = []struct {
+	enc  string
+	in   string
+	want string
+}{
+	0:  {"utf-8", "héllo", "héllo"},
+	1:  {"utf-16", "\xff\xfea\x00\xe9\x00", "aé"},
+	2:  {"utf-16", "\xfe\xff\x00a\x00\xe9", "aé"},
+	3:  {"utf-16", "\x00a\x00b", "ab"},
+	4:  {"UTF-16LE", "a\x00b\x00", "ab"},
+	5:  {"utf16be", "\x00a\x00b", "ab"},
+	6:  {"utf-16le", "=\xd8\x00\xde", "😀"},
+	7:  {"utf-16be", "\xd8=\xde\x00x", "😀\uFFFD"},
+	8:  {"utf-16be", "\xd8=\x00a", "\uFFFDa"},
+	9:  {"utf-16be", "\xd8=", "\uFFFD"},
+	10: {"utf-16", "", ""},
+	11: {"latin1", "caf\xe9 \xff", "café ÿ"},
+	12: {"ISO-8859-1", "", ""},
+	13: {"utf-16le", "\xff\xfea\x00", "a"},
+	14: {"utf-16be", "\xfe\xff\x00a", "a"},
+	15: {"utf-16le", "\xfe\xffa\x00", "\uFFFEa"},
+}
+
+func TestDecoder(t *testing.T) {
+	for i, tt := range decodeTests {
+		d, err := NewDecoder(bytes.NewReader([]byte(tt.in)), tt.enc)
+		if err != nil {
+			t.Errorf("test %d: unexpected err: %v", i, err)
+			continue
+		}
+		got, err := ioutil.ReadAll(d)
+		if err != nil {
+			t.Errorf("test %d: unexpected err: %v", i, err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("test %d:\n got: %q\nwant: %q", i, got, tt.want)
+		}
+
+		// Read one byte at a time to exercise incomplete input.
+		d, _ = NewDecoder(iotest.OneByteReader(bytes.NewReader([]byte(tt.in))), tt.enc)
+		got, err = ioutil.ReadAll(d)
+		if err != nil {
+			t.Errorf("test %d: one byte: unexpected err: %v", i, err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("test %d: one byte:\n got: %q\nwant: %q", i, got, tt.want)
+		}
+	}
+}
+
+var encodeTests = []struct {
+	enc  string
+	in   string
+	want string
+}{
+	0: {"utf-8", "héllo", "héllo"},
+	1: {"utf-16", "aé", "\xff\xfea\x00\xe9\x00"},
+	2: {"utf-16le", "aé", "a\x00\xe9\x00"},
+	3: {"utf-16be", "a😀", "\x00a\xd8=\xde\x00"},
+	4: {"latin1", "café ÿ €", "caf\xe9 \xff ?"},
+	5: {"latin1", "a\xe2\x82", "a??"},
+	6: {"utf-16be", "a\xe2\x82", "\x00a\xff\xfd\xff\xfd"},
+}
+
+func TestEncoder(t *testing.T) {
+	for i, tt := range encodeTests {
+		var b bytes.Buffer
+		e, err := NewEncoder(&b, tt.enc)
+		if err != nil {
+			t.Errorf("test %d: unexpected err: %v", i, err)
+			continue
+		}
+		// Split the input between all the bytes, so that
+		// multibyte runes span several writes.
+		for j := 0; j < len(tt.in); j++ {
+			if _, err := e.Write([]byte(tt.in[j : j+1])); err != nil {
+				t.Errorf("test %d: unexpected err: %v", i, err)
+			}
+		}
+		if err := e.Close(); err != nil {
+			t.Errorf("test %d: unexpected err: %v", i, err)
+		}
+		if got := b.String(); got != tt.want {
+			t.Errorf("test %d:\n got: %q\nwant: %q", i, got, tt.want)
+		}
+	}
+}
+
+func TestUnsupported(t *testing.T) {
+	if err := Check("ebcdic"); err == nil {
+		t.Error("Check: expected an error")
+	}
+	if _, err := NewDecoder(bytes.NewReader(nil), "ebcdic"); err == nil {
+		t.Error("NewDecoder: expected an error")
+	}
+	if _, err := NewEncoder(ioutil.Discard, "ebcdic"); err == nil {
+		t.Error("NewEncoder: expected an error")
+	}
+}
@@ -2,14 +2,13 @@ package compiler
 
 import (
 	"io"
-	"sync"
+	"os"
 
+	"github.com/mibk/hawk/charset"
 	"github.com/mibk/hawk/compiler/internal/hawkc"
 	"github.com/mibk/hawk/scan"
 )
 
-var mu sync.Mutex
-
 // A Program represents a compiled Hawk program.
 type Program struct {
 	// FieldSep specifies the default field separator, FS.
@@ -19,25 +18,173 @@ type Program struct {
 	// characters as a separator.
 	FieldSep string
 
-	prog hawkc.Program
+	// RowSep specifies the default record separator, RS.
+	// If RowSep is the empty string, records are separated
+	// by newlines.
+	RowSep string
+
+	// InputEncoding is the encoding of the input, which is
+	// converted to UTF-8 before it is split into records.
+	// OutputEncoding is the encoding the output is converted
+	// to. See package charset for the supported encodings.
+	// The empty string means UTF-8.
+	InputEncoding  string
+	OutputEncoding string
+
+	// Vars are var=value assignments performed before the BEGIN
+	// actions are run. Escape sequences in the values are replaced
+	// as in string literals.
+	Vars []string
+
+	// Args are the command-line operands: the names of the input
+	// files and var=value assignments. They are available to the
+	// program as ARGV[1] through ARGV[ARGC-1].
+	Args []string
+
+	// Open opens the named input file, which is the operand
+	// ARGV[i]. If Open is nil, os.Open is used. Input files are
+	// opened only when they are reached and closed, if they
+	// implement io.Closer, when they have been read.
+	Open func(name string, i int) (scan.Source, error)
+
+	// Warn, if not nil, is called with the error of each input
+	// file that cannot be opened, and the file is skipped.
+	// Otherwise, such an error stops the program.
+	Warn func(err error)
+
+	// Output, if not nil, returns the writer for the output of
+	// the pattern actions while the named input file is read,
+	// e.g. to edit the file in place. It is called when each
+	// file is opened, even if the file is empty, and the writer
+	// is closed, if it is an io.Closer, as soon as the file has
+	// been read. The output of BEGIN and END actions is still
+	// written to the writer passed to Run.
+	Output func(name string) (io.Writer, error)
+
+	// Bignum turns on the arbitrary-precision arithmetic. Numbers
+	// are then exact rationals, so that e.g. 14.67 + 0.01 is exactly
+	// 14.68, and the precision of arithmetic functions such as sqrt
+	// is given in bits by PREC.
+	Bignum bool
+
+	// Parallel, if greater than 1, is the number of input files
+	// processed concurrently, each by its own copy of the program.
+	// The output of the pattern actions is written in the order
+	// of the input files, and the global variables of the copies
+	// are merged before the END actions are run. By default,
+	// numbers are added up, other scalar values are taken from
+	// the last input file that changed them, and arrays are
+	// merged element by element, or concatenated if they are
+	// not associative. The program can declare other strategies
+	// for its variables in the MERGE array: "sum", "min", "max",
+	// "first" or "last". NR counts only the records of the
+	// current file until the END actions are run.
+	Parallel int
+
+	prog *hawkc.Program
 }
 
 // Compile compiles a Hawk program (name) from src. name is there
 // only for better error printing.
 func Compile(name string, src io.Reader) (*Program, error) {
-	mu.Lock()
-	defer mu.Unlock()
 	p, err := hawkc.Compile(name, src)
 	if err != nil {
 		return nil, err
 	}
-	return &Program{prog: *p}, nil
+	return &Program{prog: p}, nil
 }
 
-// Run runs the program. It scans src and writes output to w.
-func (p *Program) Run(w io.Writer, src scan.Source) error {
+// Run runs the program. It scans the input files from Args and
+// writes output to w. If there are no input files in Args, src is
+// scanned instead. src is also scanned in place of the file "-".
+func (p *Program) Run(w io.Writer, src scan.Source) (err error) {
 	if p.FieldSep != "" {
 		p.prog.SetFieldSep(p.FieldSep)
 	}
+	if p.RowSep != "" {
+		p.prog.SetRowSep(p.RowSep)
+	}
+	open := p.Open
+	if open == nil {
+		open = openFile
+	}
+	if err := charset.Check(p.InputEncoding); err != nil {
+		return err
+	}
+	if p.InputEncoding != "" {
+		p.prog.Open = func(name string, i int) (scan.Source, error) {
+			src, err := open(name, i)
+			if err != nil {
+				return nil, err
+			}
+			return scan.Decode(src, p.InputEncoding)
+		}
+		if src != nil {
+			src, _ = scan.Decode(src, p.InputEncoding)
+		}
+	} else {
+		p.prog.Open = open
+	}
+	if p.OutputEncoding != "" {
+		enc, err := charset.NewEncoder(w, p.OutputEncoding)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if cerr := enc.Close(); err == nil {
+				err = cerr
+			}
+		}()
+		w = enc
+	}
+	p.prog.Warn = p.Warn
+	p.prog.Output = p.Output
+	if p.Output != nil && p.OutputEncoding != "" {
+		p.prog.Output = func(name string) (io.Writer, error) {
+			w, err := p.Output(name)
+			if err != nil {
+				return nil, err
+			}
+			enc, err := charset.NewEncoder(w, p.OutputEncoding)
+			if err != nil {
+				return nil, err
+			}
+			return encodedOutput{enc, w}, nil
+		}
+	}
+	p.prog.SetBignum(p.Bignum)
+	p.prog.SetArgs(p.Args)
+	for _, v := range p.Vars {
+		if err := p.prog.Assign(v); err != nil {
+			return err
+		}
+	}
+	if p.Parallel > 1 {
+		return p.prog.RunParallel(w, src, p.Parallel)
+	}
 	return p.prog.Run(w, src)
 }
+
+func openFile(name string, _ int) (scan.Source, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// encodedOutput is an Output writer wrapped by an encoder.
+type encodedOutput struct {
+	io.WriteCloser // the encoder
+	w              io.Writer
+}
+
+func (o encodedOutput) Close() error {
+	err := o.WriteCloser.Close()
+	if c, ok := o.w.(io.Closer); ok {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
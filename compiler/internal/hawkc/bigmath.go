@@ -0,0 +1,284 @@
+package hawkc
+
+import (
+	"math"
+	"math/big"
+	"sync"
+)
+
+// The arithmetic functions in bignum mode are computed using series
+// of big.Floats. The series are summed with guardBits more bits than
+// the precision of the result, so that the rounding errors of the
+// terms don't reach it.
+const guardBits = 64
+
+func newFloat(prec uint) *big.Float {
+	return new(big.Float).SetPrec(prec)
+}
+
+// bigExp returns e**x with precision prec.
+func bigExp(x *big.Float, prec uint) *big.Float {
+	z := newFloat(prec)
+	switch {
+	case x.Sign() == 0:
+		return z.SetInt64(1)
+	case x.MantExp(nil) > 30:
+		// The exponent of the result would overflow.
+		if x.Sign() > 0 {
+			return z.SetInf(false)
+		}
+		return z
+	}
+	wp := prec + guardBits + 32 // k has up to 32 bits
+
+	// e**x = 2**k * e**r, where r = x - k*ln(2) and |r| <= ln(2)/2.
+	ln2 := bigLn2(wp)
+	k := roundInt(newFloat(wp).Quo(x, ln2))
+	r := newFloat(wp).Mul(newFloat(wp).SetInt(k), ln2)
+	r.Sub(x, r)
+
+	// The series converges faster for r / 2**8; its sum
+	// is then squared 8 times.
+	const halvings = 8
+	r.SetMantExp(r, -halvings)
+	sum, term := newFloat(wp).SetInt64(1), newFloat(wp).SetInt64(1)
+	for n := int64(1); ; n++ {
+		term.Mul(term, r)
+		term.Quo(term, newFloat(wp).SetInt64(n))
+		if negligible(term, sum, wp) {
+			break
+		}
+		sum.Add(sum, term)
+	}
+	for i := 0; i < halvings; i++ {
+		sum.Mul(sum, sum)
+	}
+	return z.SetMantExp(sum, int(k.Int64()))
+}
+
+// bigLog returns the natural logarithm of x with precision prec,
+// or nil, standing for NaN, if x is negative.
+func bigLog(x *big.Float, prec uint) *big.Float {
+	switch x.Sign() {
+	case 0:
+		return newFloat(prec).SetInf(true)
+	case -1:
+		return nil
+	}
+	wp := prec + guardBits + 32 // e has up to 32 bits
+
+	// log(x) = log(m) + e*ln(2), where x = m * 2**e and
+	// sqrt(1/2) <= m < sqrt(2).
+	m := newFloat(wp)
+	e := x.MantExp(m)
+	if m.Cmp(big.NewFloat(math.Sqrt2/2)) < 0 {
+		m.SetMantExp(m, 1)
+		e--
+	}
+
+	// log(m) = 2*atanh(t), where t = (m-1) / (m+1).
+	one := newFloat(wp).SetInt64(1)
+	t := newFloat(wp).Sub(m, one)
+	t.Quo(t, one.Add(m, one))
+	z := oddSeries(t, wp, false)
+	z.SetMantExp(z, 1)
+	if e != 0 {
+		ln2 := bigLn2(wp)
+		z.Add(z, ln2.Mul(ln2, newFloat(wp).SetInt64(int64(e))))
+	}
+	return newFloat(prec).Set(z)
+}
+
+// bigSinCos returns sin(x) and cos(x) with precision prec.
+func bigSinCos(x *big.Float, prec uint) (sin, cos *big.Float) {
+	wp := prec + guardBits
+	if e := x.MantExp(nil); e > 0 {
+		// The bits of the integer part are lost
+		// when x is reduced.
+		wp += uint(e)
+	}
+
+	// x = q*pi/2 + r, where |r| <= pi/4.
+	halfPi := bigPi(wp)
+	halfPi.SetMantExp(halfPi, -1)
+	q := roundInt(newFloat(wp).Quo(x, halfPi))
+	r := newFloat(wp).Mul(newFloat(wp).SetInt(q), halfPi)
+	r.Sub(x, r)
+
+	// sin(r) = r - r**3/3! + r**5/5! - ...
+	// cos(r) = 1 - r**2/2! + r**4/4! - ...
+	sin, cos = newFloat(wp).Set(r), newFloat(wp).SetInt64(1)
+	r2 := newFloat(wp).Mul(r, r)
+	term := newFloat(wp).SetInt64(1)
+	for n := int64(1); ; n += 2 {
+		term.Mul(term, r2)
+		term.Quo(term, newFloat(wp).SetInt64(n*(n+1)))
+		term.Neg(term)
+		if negligible(term, cos, wp) {
+			break
+		}
+		cos.Add(cos, term)
+		sin.Add(sin, newFloat(wp).Mul(newFloat(wp).Quo(term, newFloat(wp).SetInt64(n+2)), r))
+	}
+
+	switch new(big.Int).And(q, big.NewInt(3)).Int64() {
+	case 1:
+		sin, cos = cos, sin.Neg(sin)
+	case 2:
+		sin, cos = sin.Neg(sin), cos.Neg(cos)
+	case 3:
+		sin, cos = cos.Neg(cos), sin
+	}
+	return newFloat(prec).Set(sin), newFloat(prec).Set(cos)
+}
+
+// bigAtan2 returns the arc tangent of y/x with precision prec,
+// using the signs of the two to determine the quadrant.
+func bigAtan2(y, x *big.Float, prec uint) *big.Float {
+	wp := prec + guardBits
+	var z *big.Float
+	switch {
+	case x.Sign() != 0:
+		z = bigAtan(newFloat(wp).Quo(y, x), wp)
+		if x.Sign() < 0 {
+			if y.Sign() < 0 {
+				z.Sub(z, bigPi(wp))
+			} else {
+				z.Add(z, bigPi(wp))
+			}
+		}
+	case y.Sign() != 0:
+		z = bigPi(wp)
+		z.SetMantExp(z, -1)
+		if y.Sign() < 0 {
+			z.Neg(z)
+		}
+	default:
+		z = newFloat(wp)
+	}
+	return newFloat(prec).Set(z)
+}
+
+// bigAtan returns the arc tangent of t with precision prec.
+func bigAtan(t *big.Float, prec uint) *big.Float {
+	if t.Sign() == 0 {
+		return newFloat(prec)
+	}
+	wp := prec + guardBits
+	neg := t.Sign() < 0
+	t = newFloat(wp).Abs(t)
+	one := newFloat(wp).SetInt64(1)
+
+	// atan(t) = pi/2 - atan(1/t).
+	inv := t.Cmp(one) > 0
+	if inv {
+		t.Quo(one, t)
+	}
+
+	// atan(t) = 2*atan(t / (1 + sqrt(1 + t**2))), until t < 1/16.
+	halvings := 0
+	for t.MantExp(nil) > -4 {
+		u := newFloat(wp).Mul(t, t)
+		u.Add(u, one)
+		u.Sqrt(u)
+		t.Quo(t, u.Add(u, one))
+		halvings++
+	}
+	z := oddSeries(t, wp, true)
+	z.SetMantExp(z, halvings)
+
+	if inv {
+		halfPi := bigPi(wp)
+		halfPi.SetMantExp(halfPi, -1)
+		z.Sub(halfPi, z)
+	}
+	if neg {
+		z.Neg(z)
+	}
+	return newFloat(prec).Set(z)
+}
+
+// A constCache holds a constant computed with the highest
+// precision requested so far.
+type constCache struct {
+	mu      sync.Mutex
+	f       *big.Float
+	compute func(prec uint) *big.Float
+}
+
+func (c *constCache) get(prec uint) *big.Float {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.f == nil || c.f.Prec() < prec {
+		c.f = c.compute(prec)
+	}
+	return newFloat(prec).Set(c.f)
+}
+
+var (
+	piCache  = &constCache{compute: computePi}
+	ln2Cache = &constCache{compute: computeLn2}
+)
+
+// bigPi returns pi with precision prec.
+func bigPi(prec uint) *big.Float { return piCache.get(prec) }
+
+// bigLn2 returns ln(2) with precision prec.
+func bigLn2(prec uint) *big.Float { return ln2Cache.get(prec) }
+
+// computePi returns pi with precision prec. It uses Machin's
+// formula, pi = 16*atan(1/5) - 4*atan(1/239).
+func computePi(prec uint) *big.Float {
+	wp := prec + guardBits
+	one := newFloat(wp).SetInt64(1)
+	a := oddSeries(newFloat(wp).Quo(one, newFloat(wp).SetInt64(5)), wp, true)
+	b := oddSeries(newFloat(wp).Quo(one, newFloat(wp).SetInt64(239)), wp, true)
+	a.SetMantExp(a, 4)
+	b.SetMantExp(b, 2)
+	return newFloat(prec).Sub(a, b)
+}
+
+// computeLn2 returns ln(2) = 2*atanh(1/3) with precision prec.
+func computeLn2(prec uint) *big.Float {
+	wp := prec + guardBits
+	z := oddSeries(newFloat(wp).Quo(newFloat(wp).SetInt64(1), newFloat(wp).SetInt64(3)), wp, false)
+	z.SetMantExp(z, 1)
+	return newFloat(prec).Set(z)
+}
+
+// oddSeries returns t + t**3/3 + t**5/5 + ..., which is atanh(t),
+// or t - t**3/3 + t**5/5 - ..., which is atan(t), if alternating
+// is set. |t| must be less than 1.
+func oddSeries(t *big.Float, prec uint, alternating bool) *big.Float {
+	sum, pow := newFloat(prec).Set(t), newFloat(prec).Set(t)
+	t2 := newFloat(prec).Mul(t, t)
+	if alternating {
+		t2.Neg(t2)
+	}
+	for n := int64(3); ; n += 2 {
+		pow.Mul(pow, t2)
+		term := newFloat(prec).Quo(pow, newFloat(prec).SetInt64(n))
+		if negligible(term, sum, prec) {
+			break
+		}
+		sum.Add(sum, term)
+	}
+	return sum
+}
+
+// negligible reports whether adding term to sum doesn't change
+// the first prec bits of sum.
+func negligible(term, sum *big.Float, prec uint) bool {
+	return term.Sign() == 0 || term.MantExp(nil) < sum.MantExp(nil)-int(prec)
+}
+
+// roundInt returns x rounded to the nearest integer,
+// rounding half away from zero.
+func roundInt(x *big.Float) *big.Int {
+	half := big.NewFloat(0.5)
+	if x.Sign() < 0 {
+		half.Neg(half)
+	}
+	i, _ := newFloat(x.Prec()+1).Add(x, half).Int(nil)
+	return i
+}
@@ -1,15 +1,59 @@
 package hawkc
 
 import (
+	"fmt"
 	"io"
 	"math"
+	"math/big"
+	"regexp"
+	"sort"
+	"strings"
 
 	"github.com/mibk/hawk/value"
 )
 
+// builtins are the built-in functions. They are called with the call
+// expression and evaluate the arguments themselves.
+var builtins = map[string]func(c *CallExpr, w io.Writer) value.Value{
+	"len":      length,
+	"sprintf":  sprintf,
+	"patsplit": patsplit,
+
+	// Array functions:
+	"keys":    keys,
+	"values":  values,
+	"sort":    sortArray,
+	"asort":   asort,
+	"asorti":  asorti,
+	"reverse": reverse,
+	"slice":   slice,
+	"join":    join,
+
+	// Functions taking functions:
+	"map":    mapArray,
+	"filter": filter,
+	"reduce": reduce,
+}
+
+func init() {
+	for name := range aritFns {
+		builtins[name] = arit
+	}
+}
+
 func evalArgs(di debugInfo, w io.Writer, fname string, nargs int, args []Expr) []value.Value {
-	if len(args) != nargs {
-		di.throw("%s: %d != %d: argument count mismatch", fname, nargs, len(args))
+	return evalOptArgs(di, w, fname, nargs, nargs, args)
+}
+
+// evalOptArgs is evalArgs for functions with optional arguments.
+// There must be from min to max arguments.
+func evalOptArgs(di debugInfo, w io.Writer, fname string, min, max int, args []Expr) []value.Value {
+	if len(args) < min || len(args) > max {
+		want := fmt.Sprint(min)
+		if min != max {
+			want = fmt.Sprintf("%d-%d", min, max)
+		}
+		di.throw("%s: %s != %d: argument count mismatch", fname, want, len(args))
 	}
 	vals := make([]value.Value, len(args))
 	for i := range args {
@@ -33,6 +77,317 @@ func convertArgsToScalars(di debugInfo, w io.Writer, fname string, nargs int, ar
 	return vals
 }
 
+// arrayArg returns the argument v of the call c as an array.
+func arrayArg(c *CallExpr, v value.Value) *value.Array {
+	a, ok := v.Array()
+	if !ok {
+		c.throw("%s: argument is not an array", c.Fun)
+	}
+	return a
+}
+
+// funcArg returns the function value, or the function named by
+// the argument v of the call c, which must take nargs arguments.
+func funcArg(c *CallExpr, v value.Value, nargs int) *value.Func {
+	f, ok := c.root.lookupFunc(v)
+	if !ok {
+		c.throw("%s: unknown function: %s", c.Fun, v)
+	}
+	if n := len(f.Impl.(*closure).fn.Args); n != nargs {
+		c.throw("%s: %v must take %d arguments, not %d", c.Fun, f, nargs, n)
+	}
+	return f
+}
+
+func length(c *CallExpr, w io.Writer) value.Value {
+	vals := evalArgs(c.debugInfo, w, c.Fun, 1, c.Args)
+	return value.NewInt(int64(vals[0].Len()))
+}
+
+func sprintf(c *CallExpr, w io.Writer) value.Value {
+	format, vals, err := formatPrintfArgs(w, "sprintf", c.Args, c.root.bignum)
+	if err != nil {
+		c.throw("%v", err)
+	}
+	return value.NewString(fmt.Sprintf(format, vals...))
+}
+
+func patsplit(c *CallExpr, w io.Writer) value.Value {
+	vals := convertArgsToScalars(c.debugInfo, w, c.Fun, 2, c.Args)
+	rx, err := regexp.Compile(vals[1].String())
+	if err != nil {
+		c.throw("patsplit: invalid regexp")
+	}
+	rx.Longest()
+	arr := value.NewArray()
+	for _, f := range rx.FindAllString(vals[0].String(), -1) {
+		arr.Put(nil, value.NewStrNum(f))
+	}
+	return arr
+}
+
+// keys returns the keys of an array as a new array.
+func keys(c *CallExpr, w io.Writer) value.Value {
+	a := arrayArg(c, evalArgs(c.debugInfo, w, c.Fun, 1, c.Args)[0])
+	z := value.NewArray()
+	for _, k := range a.Keys() {
+		k := k
+		z.Put(nil, &k)
+	}
+	return z
+}
+
+// values returns the values of an array as a new array.
+func values(c *CallExpr, w io.Writer) value.Value {
+	a := arrayArg(c, evalArgs(c.debugInfo, w, c.Fun, 1, c.Args)[0])
+	return newList(elems(a, a.Keys()))
+}
+
+// sortArray returns the values of an array sorted as by the comparison
+// operators, or by a function f(x, y) that returns a negative number,
+// zero or a positive number if x should be ordered before, as or after
+// y, respectively. Sorting is stable.
+func sortArray(c *CallExpr, w io.Writer) value.Value {
+	return sortValues(c, w, evalOptArgs(c.debugInfo, w, c.Fun, 1, 2, c.Args))
+}
+
+// sortValues sorts the array vals[0] as sort, using the function
+// vals[1] if it is present. vals are the evaluated arguments of c.
+func sortValues(c *CallExpr, w io.Writer, vals []value.Value) value.Value {
+	a := arrayArg(c, vals[0])
+	list := elems(a, a.Keys())
+	cmp := compareValues
+	if len(vals) == 2 {
+		fn := funcArg(c, vals[1], 2)
+		cmp = func(x, y value.Value) int {
+			v, ok := c.root.call(w, fn, []value.Value{x, y}).Scalar()
+			if !ok {
+				c.throw("%s: %v returned a non-scalar value", c.Fun, fn)
+			}
+			return sign(v)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return cmp(list[i], list[j]) < 0
+	})
+	return newList(list)
+}
+
+// sign returns -1, 0 or 1 if the result v of a comparison function
+// is negative, zero or positive. v is not truncated, so a function
+// may return a fraction, as x - y does for non-integral numbers.
+func sign(v *value.Scalar) int {
+	switch f := v.Float64(); {
+	case f < 0:
+		return -1
+	case f > 0:
+		return 1
+	}
+	return 0
+}
+
+// asort returns the values of an array in the order given as for
+// SORTED_IN, or sorted as by sort if no order is given.
+func asort(c *CallExpr, w io.Writer) value.Value {
+	vals := evalOptArgs(c.debugInfo, w, c.Fun, 1, 2, c.Args)
+	if len(vals) == 1 {
+		return sortValues(c, w, vals)
+	}
+	a := arrayArg(c, vals[0])
+	return newList(elems(a, c.root.orderedKeys(w, c.debugInfo, a, vals[1], c.Fun)))
+}
+
+// asorti returns the keys of an array in the order given as for
+// SORTED_IN, or "@ind_str_asc" if no order is given.
+func asorti(c *CallExpr, w io.Writer) value.Value {
+	vals := evalOptArgs(c.debugInfo, w, c.Fun, 1, 2, c.Args)
+	var order value.Value = value.NewString("@ind_str_asc")
+	if len(vals) == 2 {
+		order = vals[1]
+	}
+	a := arrayArg(c, vals[0])
+	z := value.NewArray()
+	for _, k := range c.root.orderedKeys(w, c.debugInfo, a, order, c.Fun) {
+		k := k
+		z.Put(nil, &k)
+	}
+	return z
+}
+
+// reverse returns the values of an array in the reverse order
+// as a new array, or a string with its characters reversed.
+func reverse(c *CallExpr, w io.Writer) value.Value {
+	v := evalArgs(c.debugInfo, w, c.Fun, 1, c.Args)[0]
+	if s, ok := v.Scalar(); ok {
+		r := []rune(s.String())
+		for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+			r[i], r[j] = r[j], r[i]
+		}
+		return value.NewString(string(r))
+	}
+	a := arrayArg(c, v)
+	list := elems(a, a.Keys())
+	for i, j := 0, len(list)-1; i < j; i, j = i+1, j-1 {
+		list[i], list[j] = list[j], list[i]
+	}
+	return newList(list)
+}
+
+// slice returns the values of an array from the position i up
+// to, but not including, j as a new array, or the characters of
+// a string from i to j. Negative positions count from the end.
+// If j is omitted, the rest of the array or string is returned.
+func slice(c *CallExpr, w io.Writer) value.Value {
+	vals := evalOptArgs(c.debugInfo, w, c.Fun, 2, 3, c.Args)
+	var pos []int
+	for _, v := range vals[1:] {
+		s, ok := v.Scalar()
+		if !ok {
+			c.throw("%s: position is not a scalar value", c.Fun)
+		}
+		pos = append(pos, s.Int())
+	}
+	if len(pos) == 1 {
+		pos = append(pos, math.MaxInt32)
+	}
+	v, err := sliceValue(vals[0], pos[0], pos[1])
+	if err != nil {
+		c.throw("%s: %v", c.Fun, err)
+	}
+	return v
+}
+
+// sliceValue returns the characters of the string v, or the values
+// of the non-associative array v, from position i up to, but not
+// including, j. Negative positions count from the end.
+func sliceValue(v value.Value, i, j int) (value.Value, error) {
+	if s, ok := v.Scalar(); ok {
+		r := []rune(s.String())
+		i, j = sliceBounds(len(r), i, j)
+		return value.NewString(string(r[i:j])), nil
+	}
+	a, _ := v.Array()
+	if isAssociative(a) {
+		return nil, fmt.Errorf("slicing an associative array")
+	}
+	i, j = sliceBounds(a.Len(), i, j)
+	return newList(elems(a, a.Keys()[i:j])), nil
+}
+
+// sliceBounds returns the positions i and j in a sequence of
+// length n. Negative positions count from the end, and the
+// positions are clamped to the sequence.
+func sliceBounds(n, i, j int) (int, int) {
+	clamp := func(i int) int {
+		if i < 0 {
+			i += n
+		}
+		switch {
+		case i < 0:
+			return 0
+		case i > n:
+			return n
+		}
+		return i
+	}
+	i, j = clamp(i), clamp(j)
+	if j < i {
+		j = i
+	}
+	return i, j
+}
+
+// join returns the values of an array converted to strings
+// and separated by sep.
+func join(c *CallExpr, w io.Writer) value.Value {
+	vals := evalArgs(c.debugInfo, w, c.Fun, 2, c.Args)
+	a := arrayArg(c, vals[0])
+	var strs []string
+	for _, v := range elems(a, a.Keys()) {
+		strs = append(strs, c.root.conv.String(v))
+	}
+	return value.NewString(strings.Join(strs, vals[1].String()))
+}
+
+// mapArray returns a new array with the values of an array replaced by
+// the results of f(v). The keys of associative arrays are kept.
+func mapArray(c *CallExpr, w io.Writer) value.Value {
+	vals := evalArgs(c.debugInfo, w, c.Fun, 2, c.Args)
+	a := arrayArg(c, vals[0])
+	f := funcArg(c, vals[1], 1)
+	assoc := isAssociative(a)
+	z := value.NewArray()
+	for _, k := range a.Keys() {
+		k := k
+		v := c.root.call(w, f, []value.Value{a.Get(&k)})
+		if assoc {
+			z.Put(&k, v)
+		} else {
+			z.Put(nil, v)
+		}
+	}
+	return z
+}
+
+// filter returns a new array with the elements of an array for which
+// f(v) is true. The keys of associative arrays are kept.
+func filter(c *CallExpr, w io.Writer) value.Value {
+	vals := evalArgs(c.debugInfo, w, c.Fun, 2, c.Args)
+	a := arrayArg(c, vals[0])
+	f := funcArg(c, vals[1], 1)
+	assoc := isAssociative(a)
+	z := value.NewArray()
+	for _, k := range a.Keys() {
+		k := k
+		v := a.Get(&k)
+		b, ok := c.root.call(w, f, []value.Value{v}).Scalar()
+		if !ok {
+			c.throw("%s: %v returned a non-scalar value", c.Fun, f)
+		}
+		if !b.Bool() {
+			continue
+		}
+		if assoc {
+			z.Put(&k, v)
+		} else {
+			z.Put(nil, v)
+		}
+	}
+	return z
+}
+
+// reduce returns the result of calling f(acc, v) for the values of
+// an array in order, where acc is init for the first value and the
+// result of the previous call for the others.
+func reduce(c *CallExpr, w io.Writer) value.Value {
+	vals := evalArgs(c.debugInfo, w, c.Fun, 3, c.Args)
+	a := arrayArg(c, vals[0])
+	f := funcArg(c, vals[1], 2)
+	acc := vals[2]
+	for _, v := range elems(a, a.Keys()) {
+		acc = c.root.call(w, f, []value.Value{acc, v})
+	}
+	return acc
+}
+
+// elems returns the values of a under keys.
+func elems(a *value.Array, keys []value.Scalar) []value.Value {
+	vals := make([]value.Value, len(keys))
+	for i := range keys {
+		vals[i] = a.Get(&keys[i])
+	}
+	return vals
+}
+
+// newList returns a new non-associative array of vals.
+func newList(vals []value.Value) *value.Array {
+	z := value.NewArray()
+	for _, v := range vals {
+		z.Put(nil, v)
+	}
+	return z
+}
+
 var aritFns = map[string]struct {
 	narg int
 	fn   func([]*value.Scalar) *value.Scalar
@@ -41,11 +396,21 @@ var aritFns = map[string]struct {
 	"atan2": {2, atan2},
 	"cos":   {1, cos},
 	"exp":   {1, exp},
-	"log":   {1, _log},
+	"log":   {1, ln},
 	"sin":   {1, sin},
 	"sqrt":  {1, sqrt},
 }
 
+// arit calls the arithmetic function of c.
+func arit(c *CallExpr, w io.Writer) value.Value {
+	dcl := aritFns[c.Fun]
+	vals := convertArgsToScalars(c.debugInfo, w, c.Fun, dcl.narg, c.Args)
+	if c.root.bignum {
+		return bigArit(c.Fun, vals, c.root.prec)
+	}
+	return dcl.fn(vals)
+}
+
 func atan2(vals []*value.Scalar) *value.Scalar {
 	return value.NewNumber(math.Atan2(vals[0].Float64(), vals[1].Float64()))
 }
@@ -58,7 +423,7 @@ func exp(vals []*value.Scalar) *value.Scalar {
 	return value.NewNumber(math.Exp(vals[0].Float64()))
 }
 
-func _log(vals []*value.Scalar) *value.Scalar {
+func ln(vals []*value.Scalar) *value.Scalar {
 	return value.NewNumber(math.Log(vals[0].Float64()))
 }
 
@@ -69,3 +434,48 @@ func sin(vals []*value.Scalar) *value.Scalar {
 func sqrt(vals []*value.Scalar) *value.Scalar {
 	return value.NewNumber(math.Sqrt(vals[0].Float64()))
 }
+
+// bigArit is the arithmetic function fn in bignum mode. It is
+// computed with precision prec, and the result is rounded to the
+// decimal digits that prec holds. Infinities and NaN are passed to
+// the float64 function.
+func bigArit(fn string, vals []*value.Scalar, prec uint) *value.Scalar {
+	args := make([]*big.Float, len(vals))
+	for i, v := range vals {
+		if v = v.Big(); !v.IsBig() {
+			return aritFns[fn].fn(vals).Big()
+		}
+		args[i] = v.BigFloat(prec + guardBits)
+	}
+	var f *big.Float
+	switch x := args[0]; fn {
+	case "atan2":
+		f = bigAtan2(x, args[1], prec)
+	case "cos":
+		_, f = bigSinCos(x, prec)
+	case "exp":
+		f = bigExp(x, prec)
+	case "log":
+		f = bigLog(x, prec)
+	case "sin":
+		f, _ = bigSinCos(x, prec)
+	case "sqrt":
+		if x.Sign() >= 0 {
+			f = newFloat(prec).Sqrt(x)
+		}
+	default:
+		panic("unknown arithmetic function: " + fn)
+	}
+	switch {
+	case f == nil:
+		return value.NewNumber(math.NaN())
+	case f.IsInf():
+		return value.NewNumber(math.Inf(f.Sign()))
+	}
+	digits := int(math.Ceil(float64(prec) * math.Log10(2)))
+	r, ok := new(big.Rat).SetString(f.Text('g', digits))
+	if !ok {
+		panic("unreachable")
+	}
+	return value.NewRat(r)
+}
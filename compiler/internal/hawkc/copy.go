@@ -0,0 +1,228 @@
+package hawkc
+
+import (
+	"fmt"
+
+	"github.com/mibk/hawk/scan"
+	"github.com/mibk/hawk/value"
+)
+
+// copy returns a copy of the compiled program p, with its own
+// syntax tree, with the same settings, but with no global
+// variables. Unlike p, it can be run concurrently with p.
+func (p *Program) copy() *Program {
+	c := NewProgram(new(scan.Scanner))
+	cp := &copier{make(map[*FuncDecl]*FuncDecl)}
+	for name, fn := range p.funcs {
+		c.funcs[name] = cp.decl(fn)
+	}
+	for _, fn := range p.lits {
+		c.lits = append(c.lits, cp.decl(fn))
+	}
+	c.begins = cp.stmts(p.begins)
+	c.pActions = cp.stmts(p.pActions)
+	c.ends = cp.stmts(p.ends)
+	analyse(c, c.sc) // p has been analysed without errors
+	c.reset(p)
+	return c
+}
+
+// reset sets the settings of p to those of p2.
+func (p *Program) reset(p2 *Program) {
+	p.sc.CopySettings(p2.sc)
+	p.outputRowSep = p2.outputRowSep
+	p.outputFieldSep = p2.outputFieldSep
+	p.bignum, p.prec = p2.bignum, p2.prec
+	p.conv = p2.conv
+	p.sortedIn = p2.sortedIn
+}
+
+// A copier copies syntax trees. The fields set by the analyser
+// are copied as well, so the copy has to be analysed again.
+type copier struct {
+	decls map[*FuncDecl]*FuncDecl // the copies of functions
+}
+
+func (c *copier) decl(fn *FuncDecl) *FuncDecl {
+	if d, ok := c.decls[fn]; ok {
+		return d
+	}
+	d := &FuncDecl{fn.debugInfo, &FuncScope{}, fn.Name, fn.Args, nil, nil}
+	c.decls[fn] = d
+	d.Body = c.stmt(fn.Body)
+	return d
+}
+
+func (c *copier) stmts(list []Stmt) []Stmt {
+	if list == nil {
+		return nil
+	}
+	l := make([]Stmt, len(list))
+	for i, s := range list {
+		l[i] = c.stmt(s)
+	}
+	return l
+}
+
+func (c *copier) block(b *BlockStmt) *BlockStmt {
+	if b == nil {
+		return nil
+	}
+	return &BlockStmt{c.stmts(b.List)}
+}
+
+func (c *copier) stmt(s Stmt) Stmt {
+	switch s := s.(type) {
+	case nil:
+		return nil
+	case *BeginAction:
+		return &BeginAction{c.block(s.BlockStmt)}
+	case *PatternAction:
+		n := *s
+		n.X = c.expr(s.X)
+		n.Body = c.block(s.Body)
+		return &n
+	case *EndAction:
+		return &EndAction{c.block(s.BlockStmt)}
+	case *BlockStmt:
+		return c.block(s)
+	case *ExprStmt:
+		return &ExprStmt{c.expr(s.X)}
+	case *PipeStmt:
+		n := *s
+		n.Stmt = c.stmt(s.Stmt)
+		return &n
+	case *IfStmt:
+		n := *s
+		n.X = c.expr(s.X)
+		n.Body = c.block(s.Body)
+		n.Else = c.stmt(s.Else)
+		return &n
+	case *SwitchStmt:
+		n := *s
+		n.Tag = c.expr(s.Tag)
+		n.Cases = make([]*CaseClause, len(s.Cases))
+		for i, cc := range s.Cases {
+			ncc := *cc
+			ncc.Exprs = c.exprs(cc.Exprs)
+			ncc.Body = c.block(cc.Body)
+			n.Cases[i] = &ncc
+		}
+		return &n
+	case *ForStmt:
+		n := *s
+		n.Init = c.stmt(s.Init)
+		n.Cond = c.expr(s.Cond)
+		n.Post = c.stmt(s.Post)
+		n.Body = c.block(s.Body)
+		return &n
+	case *DoStmt:
+		n := *s
+		n.Body = c.block(s.Body)
+		n.Cond = c.expr(s.Cond)
+		return &n
+	case *ForeachStmt:
+		n := *s
+		n.Key = c.ident(s.Key)
+		n.Val = c.ident(s.Val)
+		n.X = c.expr(s.X)
+		n.Body = c.block(s.Body)
+		return &n
+	case *StatusStmt:
+		n := *s
+		return &n
+	case *ReturnStmt:
+		n := *s
+		n.X = c.expr(s.X)
+		return &n
+	case *PrintStmt:
+		n := *s
+		n.Args = c.exprs(s.Args)
+		return &n
+	}
+	panic(fmt.Sprintf("unknown statement: %T", s))
+}
+
+func (c *copier) exprs(list []Expr) []Expr {
+	if list == nil {
+		return nil
+	}
+	l := make([]Expr, len(list))
+	for i, e := range list {
+		l[i] = c.expr(e)
+	}
+	return l
+}
+
+func (c *copier) ident(id *Ident) *Ident {
+	if id == nil {
+		return nil
+	}
+	n := *id
+	return &n
+}
+
+func (c *copier) expr(e Expr) Expr {
+	switch e := e.(type) {
+	case nil:
+		return nil
+	case *TernaryExpr:
+		n := *e
+		n.Cond = c.expr(e.Cond)
+		n.Yes = c.expr(e.Yes)
+		n.No = c.expr(e.No)
+		return &n
+	case *CallExpr:
+		n := *e
+		n.Args = c.exprs(e.Args)
+		return &n
+	case *CallValueExpr:
+		n := *e
+		n.X = c.expr(e.X)
+		n.Args = c.exprs(e.Args)
+		return &n
+	case *FuncLit:
+		return &FuncLit{Decl: c.decl(e.Decl)}
+	case *Ident:
+		return c.ident(e)
+	case *FieldExpr:
+		n := *e
+		n.X = c.expr(e.X)
+		return &n
+	case *IndexExpr:
+		n := *e
+		n.X = c.expr(e.X)
+		n.Index = c.expr(e.Index)
+		return &n
+	case *SliceExpr:
+		n := *e
+		n.X = c.expr(e.X)
+		n.Lo = c.expr(e.Lo)
+		n.Hi = c.expr(e.Hi)
+		return &n
+	case *BinaryExpr:
+		n := *e
+		n.X = c.expr(e.X)
+		n.Y = c.expr(e.Y)
+		return &n
+	case *UnaryExpr:
+		n := *e
+		n.X = c.expr(e.X)
+		return &n
+	case *AssignExpr:
+		n := *e
+		n.Left = c.expr(e.Left)
+		n.Right = c.expr(e.Right)
+		return &n
+	case *MatchExpr:
+		n := *e
+		n.X = c.expr(e.X)
+		n.Y = c.expr(e.Y)
+		return &n
+	case BasicLit:
+		return BasicLit{value.Copy(e.Val)}
+	case *ArrayLit:
+		return &ArrayLit{c.exprs(e.Elems)}
+	}
+	panic(fmt.Sprintf("unknown expression: %T", e))
+}
@@ -0,0 +1,179 @@
+package hawkc
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// allNodes is a program that uses every node type of the syntax tree.
+const allNodes = `
+func f(x) { return x * 2 }
+
+BEGIN {
+	a = [1, 2, 3]
+	a[] = -f(1)
+	g = func(y) { return y }
+	h = [g]
+	print g(a[1:2]), h[0](1), $1, c ? 1 : 2, "x" ~ "y"
+	print "x" | "cat"
+	if a {
+	} else if !b {
+	}
+	switch x {
+	case 1:
+		fallthrough
+	default:
+	}
+	for i = 0; i < 3; i++ {
+		break
+	}
+	do {
+		continue
+	} while false
+	for k, v in a {
+	}
+}
+
+$1 == "x" { print }
+
+END { printf "%d\n", NR }
+`
+
+// nodeTypes returns the names of the node types of the syntax tree:
+// the types with an Eval or Exec method, and the types the parser
+// creates other than scopes.
+func nodeTypes(t *testing.T) map[string]bool {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, ".", func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	types := make(map[string]bool)
+	structs := make(map[string]bool)
+	for _, f := range pkgs["hawkc"].Files {
+		for _, d := range f.Decls {
+			switch d := d.(type) {
+			case *ast.FuncDecl:
+				if d.Recv != nil && (d.Name.Name == "Eval" || d.Name.Name == "Exec") {
+					typ := d.Recv.List[0].Type
+					if star, ok := typ.(*ast.StarExpr); ok {
+						typ = star.X
+					}
+					types[typ.(*ast.Ident).Name] = true
+				}
+			case *ast.GenDecl:
+				for _, s := range d.Specs {
+					if s, ok := s.(*ast.TypeSpec); ok {
+						if _, ok := s.Type.(*ast.StructType); ok {
+							structs[s.Name.Name] = true
+						}
+					}
+				}
+			}
+		}
+	}
+	grammar, err := ioutil.ReadFile("hawk.y")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range regexp.MustCompile(`&?([A-Z]\w*)\{`).FindAllStringSubmatch(string(grammar), -1) {
+		if name := m[1]; structs[name] && name != "FuncScope" {
+			types[name] = true
+		}
+	}
+	return types
+}
+
+// A treeWalker records the nodes of a syntax tree.
+type treeWalker struct {
+	types map[string]bool    // of the nodes
+	ptrs  map[uintptr]string // to the nodes, with their types
+}
+
+func walkTree(p *Program) *treeWalker {
+	w := &treeWalker{make(map[string]bool), make(map[uintptr]string)}
+	w.walk(reflect.ValueOf(p.funcs))
+	w.walk(reflect.ValueOf(p.lits))
+	w.walk(reflect.ValueOf(p.begins))
+	w.walk(reflect.ValueOf(p.pActions))
+	w.walk(reflect.ValueOf(p.ends))
+	return w
+}
+
+// isNode reports whether t is a type of syntax tree nodes, that is
+// a struct type of this package other than those of the program and
+// its scopes, which are shared by the nodes.
+func isNode(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct || t.PkgPath() != reflect.TypeOf(Program{}).PkgPath() {
+		return false
+	}
+	switch t.Name() {
+	case "Program", "FuncScope", "frame", "debugInfo":
+		return false
+	}
+	return true
+}
+
+func (w *treeWalker) walk(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() || !isNode(v.Type().Elem()) || w.ptrs[v.Pointer()] != "" {
+			return
+		}
+		w.ptrs[v.Pointer()] = v.Type().Elem().Name()
+		w.walk(v.Elem())
+	case reflect.Interface:
+		if !v.IsNil() {
+			w.walk(v.Elem())
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			w.walk(v.Index(i))
+		}
+	case reflect.Map:
+		for _, k := range v.MapKeys() {
+			w.walk(v.MapIndex(k))
+		}
+	case reflect.Struct:
+		if !isNode(v.Type()) {
+			return
+		}
+		w.types[v.Type().Name()] = true
+		for i := 0; i < v.NumField(); i++ {
+			w.walk(v.Field(i))
+		}
+	}
+}
+
+func TestCopy(t *testing.T) {
+	p, err := Compile("copy", strings.NewReader(allNodes))
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig := walkTree(p)
+	for name := range nodeTypes(t) {
+		if !orig.types[name] {
+			t.Errorf("%s is not used by the test program", name)
+		}
+	}
+	c := walkTree(p.copy())
+	for name := range orig.types {
+		if !c.types[name] {
+			t.Errorf("%s is not copied", name)
+		}
+	}
+	for ptr, name := range c.ptrs {
+		if orig.ptrs[ptr] != "" {
+			t.Errorf("a %s node is shared by the copy", name)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package hawkc
 import (
 	"fmt"
 	"io"
+	"math"
 	"regexp"
 
 	"github.com/mibk/hawk/scan"
@@ -31,56 +32,94 @@ func (t *TernaryExpr) Eval(w io.Writer) value.Value {
 	return t.No.Eval(w)
 }
 
+// A CallExpr calls a built-in function, a named function,
+// or a function value stored in the variable Fun.
 type CallExpr struct {
 	debugInfo
-	Fun  string
-	Args []Expr
+	root  *Program
+	Fun   string
+	Args  []Expr
+	scope Scope
 }
 
 func (c *CallExpr) Eval(w io.Writer) value.Value {
-	switch c.Fun {
-	case "len":
-		vals := evalArgs(c.debugInfo, w, c.Fun, 1, c.Args)
-		return value.NewNumber(float64(vals[0].Len()))
-	case "sprintf":
-		format, vals, err := formatPrintfArgs(w, "sprintf", c.Args)
-		if err != nil {
-			c.throw("%v", err)
-		}
-		return value.NewString(fmt.Sprintf(format, vals...))
+	if fn, ok := builtins[c.Fun]; ok {
+		return fn(c, w)
 	}
-
-	// Arithmetic functions:
-	if dcl, ok := aritFns[c.Fun]; ok {
-		vals := convertArgsToScalars(c.debugInfo, w, c.Fun, dcl.narg, c.Args)
-		return dcl.fn(vals)
+	if fn, ok := c.root.funcs[c.Fun]; ok {
+		return callFunc(c.debugInfo, w, c.root, funcValue(fn), c.Args)
 	}
-
-	fn, ok := ast.funcs[c.Fun]
+	f, ok := c.scope.Get(c.Fun).(*value.Func)
 	if !ok {
 		c.throw("unknown function: %s", c.Fun)
 	}
-	vals := convertArgsToScalars(c.debugInfo, w, c.Fun, len(fn.Args), c.Args)
-	fn.scope.Push()
-	defer fn.scope.Pull()
-	for i, n := range fn.Args {
-		fn.scope.Put(n, vals[i])
+	return callFunc(c.debugInfo, w, c.root, f, c.Args)
+}
+
+// A CallValueExpr calls the function value X, e.g. f[0](x).
+type CallValueExpr struct {
+	debugInfo
+	root *Program
+	X    Expr
+	Args []Expr
+}
+
+func (c *CallValueExpr) Eval(w io.Writer) value.Value {
+	f, ok := c.X.Eval(w).(*value.Func)
+	if !ok {
+		c.throw("calling a non-function value")
 	}
-	fn.Body.Exec(w)
-	if ast.retval != nil {
-		v := ast.retval
-		ast.retval = nil
-		return v
+	return callFunc(c.debugInfo, w, c.root, f, c.Args)
+}
+
+// callFunc calls the function value f with the arguments args,
+// which must be scalar values or functions.
+func callFunc(di debugInfo, w io.Writer, p *Program, f *value.Func, args []Expr) value.Value {
+	fn := f.Impl.(*closure).fn
+	if len(args) != len(fn.Args) {
+		di.throw("%v: %d != %d: argument count mismatch", f, len(fn.Args), len(args))
 	}
-	return value.NewBool(false)
+	vals := make([]value.Value, len(args))
+	for i, e := range args {
+		v := e.Eval(w)
+		if _, ok := v.(*value.Func); !ok {
+			s, ok := v.Scalar()
+			if !ok {
+				di.throw("%v: all arguments must be scalar values or functions", f)
+			}
+			v = s
+		}
+		vals[i] = v
+	}
+	return p.call(w, f, vals)
+}
+
+// A FuncLit is a function literal. Its value is a closure: the
+// function can use the variables of the enclosing function even
+// after it has returned.
+type FuncLit struct {
+	outer *FuncScope // of the enclosing function, if any
+	Decl  *FuncDecl
+}
+
+func (f *FuncLit) Eval(io.Writer) value.Value {
+	c := &closure{fn: f.Decl}
+	if f.outer != nil {
+		c.outer = f.outer.currFrame()
+	}
+	return value.NewFunc("", c)
 }
 
 type Ident struct {
 	scope Scope
 	Name  string
+	fn    *FuncDecl // the named function, if Name is one
 }
 
 func (i *Ident) Eval(io.Writer) value.Value {
+	if i.fn != nil {
+		return funcValue(i.fn)
+	}
 	return i.scope.Get(i.Name)
 }
 
@@ -91,6 +130,11 @@ type FieldExpr struct {
 }
 
 func (f *FieldExpr) Eval(w io.Writer) value.Value {
+	return value.NewStrNum(f.sc.Field(f.index(w)))
+}
+
+// index evaluates the index of the field.
+func (f *FieldExpr) index(w io.Writer) int {
 	v, ok := f.X.Eval(w).Scalar()
 	if !ok {
 		f.throw("attempting to access a field using a non-scalar value")
@@ -99,26 +143,44 @@ func (f *FieldExpr) Eval(w io.Writer) value.Value {
 	if i < 0 {
 		f.throw("attempting to access a field using a negative index")
 	}
-	return value.NewString(f.sc.Field(i))
+	return i
 }
 
 type IndexExpr struct {
 	debugInfo
+	root  *Program
 	X     Expr
 	Index Expr
 }
 
 func (ie *IndexExpr) Eval(w io.Writer) value.Value {
-	a, ok := ie.X.Eval(w).Array()
-	if !ok {
-		// TODO: This might be permitted e.g. for string.
-		ie.throw("attempting to get an index of a scalar value")
-	}
+	x := ie.X.Eval(w)
 	index, ok := ie.Index.Eval(w).Scalar()
 	if !ok {
 		ie.throw("indexing an array using a non-scalar value")
 	}
-	v := a.Get(index)
+	if s, ok := x.(*value.Scalar); ok {
+		// Strings are indexed by characters.
+		r := []rune(s.String())
+		i := index.Int()
+		if i < 0 {
+			i += len(r)
+		}
+		if i < 0 || i >= len(r) {
+			ie.throw("string index %d out of range [0:%d]", index.Int(), len(r))
+		}
+		return value.NewString(string(r[i]))
+	}
+	a, ok := x.Array()
+	if !ok {
+		ie.throw("attempting to get an index of a scalar value")
+	}
+	return elem(a, ie.root.conv.Key(index))
+}
+
+// elem returns the element of a at key.
+func elem(a *value.Array, key *value.Scalar) value.Value {
+	v := a.Get(key)
 	if v == nil {
 		// TODO: Return a nil value?
 		return value.NewBool(false)
@@ -126,6 +188,98 @@ func (ie *IndexExpr) Eval(w io.Writer) value.Value {
 	return v
 }
 
+// An AssignExpr is x = y, or x op= y if Op is set. Its value is the
+// assigned value, or the previous value of x if Post is set, as in
+// x++. The index of x is evaluated only once.
+type AssignExpr struct {
+	debugInfo
+	root  *Program
+	Op    ExprOp
+	Left  Expr
+	Right Expr
+	Post  bool
+}
+
+func (e *AssignExpr) Eval(w io.Writer) value.Value {
+	var get func() value.Value
+	var put func(value.Value)
+	switch x := e.Left.(type) {
+	case *Ident:
+		get = func() value.Value { return x.scope.Get(x.Name) }
+		put = func(v value.Value) { x.scope.Put(x.Name, v) }
+	case *IndexExpr:
+		a, ok := x.X.Eval(w).Array()
+		if !ok {
+			e.throw("assigning to a scalar value using index expression")
+		}
+		var index *value.Scalar
+		if x.Index != nil {
+			index, ok = x.Index.Eval(w).Scalar()
+			if !ok {
+				e.throw("indexing an array using a non-scalar value")
+			}
+			index = e.root.conv.Key(index)
+		}
+		get = func() value.Value { return elem(a, index) }
+		put = func(v value.Value) { a.Put(index, v) }
+	case *FieldExpr:
+		i := x.index(w)
+		get = func() value.Value { return value.NewStrNum(x.sc.Field(i)) }
+		put = func(v value.Value) { x.sc.SetField(i, e.root.conv.String(v), e.root.outputFieldSep) }
+	default:
+		panic(fmt.Sprintf("unknown assignment type: %T", x))
+	}
+	if e.Op == 0 {
+		v := e.Right.Eval(w)
+		put(v)
+		return v
+	}
+	old := get()
+	v := arith(e.debugInfo, e.root, e.Op, old, e.Right.Eval(w))
+	put(v)
+	if e.Post {
+		if z, _ := old.Scalar(); e.root.bignum {
+			return z.Big()
+		}
+		return number(old)
+	}
+	return v
+}
+
+// A SliceExpr is x[lo:hi]. It returns the characters of a string,
+// or the values of a non-associative array, from lo up to, but not
+// including, hi. Both lo and hi are optional, and negative indexes
+// count from the end.
+type SliceExpr struct {
+	debugInfo
+	X      Expr
+	Lo, Hi Expr
+}
+
+func (se *SliceExpr) Eval(w io.Writer) value.Value {
+	x := se.X.Eval(w)
+	lo, hi := 0, math.MaxInt32
+	if se.Lo != nil {
+		lo = se.index(w, se.Lo)
+	}
+	if se.Hi != nil {
+		hi = se.index(w, se.Hi)
+	}
+	v, err := sliceValue(x, lo, hi)
+	if err != nil {
+		se.throw("%v", err)
+	}
+	return v
+}
+
+func (se *SliceExpr) index(w io.Writer, e Expr) int {
+	v, ok := e.Eval(w).Scalar()
+	if !ok {
+		se.throw("slicing using a non-scalar value")
+	}
+	return v.Int()
+}
+
 type ExprOp int
 
 const (
@@ -135,6 +289,13 @@ const (
 	Mul           // x * y
 	Div           // x / y
 	Mod           // x % y
+	Pow           // x ** y
+	And           // x & y
+	Or            // x | y
+	Xor           // x ^ y
+	AndNot        // x &^ y
+	Shl           // x << y
+	Shr           // x >> y
 	OrOr          // x || y
 	AndAnd        // x && y
 	Eq            // x == y
@@ -147,50 +308,23 @@ const (
 	Plus  // +expr
 	Minus // -expr
 	Not   // !expr
+	Compl // ^expr
 
 	Concat // x . y
 )
 
 type BinaryExpr struct {
 	debugInfo
-	Op ExprOp
-	X  Expr
-	Y  Expr
+	root *Program
+	Op   ExprOp
+	X    Expr
+	Y    Expr
 }
 
 func (e *BinaryExpr) Eval(w io.Writer) value.Value {
-	var z value.Scalar
 	switch e.Op {
-	case Add, Sub, Mul, Div, Mod, Concat:
-		v, v2 := e.X.Eval(w), e.Y.Eval(w)
-		l, ok := v.Scalar()
-		r, ok2 := v2.Scalar()
-		if !ok || !ok2 {
-			if e.Op == Add {
-				a, ok := v.Array()
-				a2, ok2 := v2.Array()
-				if ok && ok2 {
-					return value.MergeArrays(a, a2)
-				}
-			}
-			e.throw("unsupported types for binary expression: %V and %V", v, v2)
-		}
-		switch e.Op {
-		case Add:
-			z.Add(l, r)
-		case Sub:
-			z.Sub(l, r)
-		case Mul:
-			z.Mul(l, r)
-		case Div:
-			z.Div(l, r)
-		case Mod:
-			z.Mod(l, r)
-		case Concat:
-			z.Concat(l, r)
-		default:
-			panic("unreachable")
-		}
+	case Add, Sub, Mul, Div, Mod, Pow, And, Or, Xor, AndNot, Shl, Shr, Concat:
+		return arith(e.debugInfo, e.root, e.Op, e.X.Eval(w), e.Y.Eval(w))
 	case OrOr, AndAnd:
 		lval, ok := e.X.Eval(w).Scalar()
 		if !ok {
@@ -240,13 +374,78 @@ func (e *BinaryExpr) Eval(w io.Writer) value.Value {
 		}
 		return value.NewBool(b)
 	}
+}
+
+// arith returns the result of the arithmetic operation op,
+// including concatenation, on v and v2.
+func arith(di debugInfo, p *Program, op ExprOp, v, v2 value.Value) value.Value {
+	var z value.Scalar
+	l, ok := v.Scalar()
+	r, ok2 := v2.Scalar()
+	if !ok || !ok2 {
+		if op == Add {
+			a, ok := v.Array()
+			a2, ok2 := v2.Array()
+			if ok && ok2 {
+				return value.MergeArrays(a, a2)
+			}
+		}
+		di.throw("unsupported types for binary expression: %V and %V", v, v2)
+	}
+	if p.bignum && op != Concat {
+		l, r = l.Big(), r.Big()
+	}
+	switch op {
+	case Add:
+		z.Add(l, r)
+	case Sub:
+		z.Sub(l, r)
+	case Mul:
+		z.Mul(l, r)
+	case Div:
+		z.Div(l, r)
+	case Mod:
+		z.Mod(l, r)
+	case Pow:
+		if err := value.CheckPow(l, r); err != nil {
+			di.throw("%v ** %v: %v", l, r, err)
+		}
+		z.Pow(l, r)
+	case And:
+		z.And(l, r)
+	case Or:
+		z.Or(l, r)
+	case Xor:
+		z.Xor(l, r)
+	case AndNot:
+		z.AndNot(l, r)
+	case Shl, Shr:
+		n := r.Int64()
+		if n < 0 {
+			di.throw("negative shift count: %v", r)
+		}
+		if op == Shl {
+			if err := value.CheckLsh(l, uint(n)); err != nil {
+				di.throw("%v << %v: %v", l, r, err)
+			}
+			z.Lsh(l, uint(n))
+		} else {
+			z.Rsh(l, uint(n))
+		}
+	case Concat:
+		conv := &p.conv
+		z = *value.NewString(conv.String(l) + conv.String(r))
+	default:
+		panic("unreachable")
+	}
 	return &z
 }
 
 type UnaryExpr struct {
 	debugInfo
-	Op ExprOp
-	X  Expr
+	root *Program
+	Op   ExprOp
+	X    Expr
 }
 
 func (e *UnaryExpr) Eval(w io.Writer) value.Value {
@@ -256,8 +455,15 @@ func (e *UnaryExpr) Eval(w io.Writer) value.Value {
 	}
 	var z value.Scalar
 	switch e.Op {
-	case Minus:
-		z.Neg(v)
+	case Minus, Compl:
+		if e.root.bignum {
+			v = v.Big()
+		}
+		if e.Op == Minus {
+			z.Neg(v)
+		} else {
+			z.Not(v)
+		}
 	case Not:
 		return value.NewBool(!v.Bool())
 	default:
@@ -274,17 +480,21 @@ type MatchExpr struct {
 }
 
 func (m *MatchExpr) Eval(w io.Writer) value.Value {
-	l, r := m.X.Eval(w), m.Y.Eval(w)
+	return value.NewBool(m.match(m.X.Eval(w), m.Y.Eval(w)) == m.Match)
+}
+
+// match reports whether l matches the regular expression r.
+func (di debugInfo) match(l, r value.Value) bool {
 	x, ok := l.Scalar()
 	y, ok2 := r.Scalar()
 	if !ok || !ok2 || y.Type() != value.String {
-		m.throw("invalid types for regexp matching: %V ~ %V", l, r)
+		di.throw("invalid types for regexp matching: %V ~ %V", l, r)
 	}
 	rx, err := regexp.Compile(y.String())
 	if err != nil {
-		m.throw("invalid regexp")
+		di.throw("invalid regexp")
 	}
-	return value.NewBool(rx.MatchString(x.String()) == m.Match)
+	return rx.MatchString(x.String())
 }
 
 type BasicLit struct {
@@ -304,3 +514,38 @@ func (al *ArrayLit) Eval(w io.Writer) value.Value {
 	}
 	return arr
 }
+
+// The following functions work on copies of scalar values
+// as converting a scalar to a number changes its type.
+
+func number(v value.Value) *value.Scalar {
+	if v == nil {
+		return value.NewInt(0)
+	}
+	s, ok := v.Scalar()
+	if !ok {
+		return value.NewInt(0)
+	}
+	c := *s
+	return c.Number()
+}
+
+func compare(v, w value.Value) int {
+	s, ok := v.Scalar()
+	s2, ok2 := w.Scalar()
+	if !ok || !ok2 {
+		return 0
+	}
+	c, c2 := *s, *s2
+	cmp, _ := c.Cmp(&c2)
+	return cmp
+}
+
+func equal(v, w value.Value) bool {
+	s, ok := v.Scalar()
+	s2, ok2 := w.Scalar()
+	if !ok || !ok2 {
+		return v.Encode() == w.Encode()
+	}
+	return s.Type() == s2.Type() && compare(v, w) == 0
+}
@@ -1,7 +1,8 @@
 // Code generated by goyacc -o hawk.go hawk.y. DO NOT EDIT.
 
-//line hawk.y:2
 // Package hawkc is the Hawk compiler.
+//
+//line hawk.y:2
 package hawkc
 
 import __yyfmt__ "fmt"
@@ -17,26 +18,21 @@ import (
 	"github.com/mibk/hawk/value"
 )
 
-var (
-	progName string
-	ast      *Program
-
-	defaultAction = &BlockStmt{[]Stmt{&PrintStmt{Fun: "print"}}}
-)
-
-//line hawk.y:22
+//line hawk.y:15
 type yySymType struct {
-	yys       int
-	sym       string
-	val       value.Value
-	symlist   []string
-	decl      Decl
-	decllist  []Decl
-	expr      Expr
-	exprlist  []Expr
-	stmt      Stmt
-	stmtlist  []Stmt
-	blockstmt *BlockStmt
+	yys        int
+	sym        string
+	val        value.Value
+	symlist    []string
+	decl       Decl
+	decllist   []Decl
+	expr       Expr
+	exprlist   []Expr
+	stmt       Stmt
+	stmtlist   []Stmt
+	blockstmt  *BlockStmt
+	caseclause *CaseClause
+	caselist   []*CaseClause
 }
 
 const IDENT = 57346
@@ -44,29 +40,50 @@ const BOOL = 57347
 const STRING = 57348
 const PRINT = 57349
 const NUM = 57350
-const IF = 57351
-const ELSE = 57352
-const FOR = 57353
-const IN = 57354
-const BREAK = 57355
-const CONTINUE = 57356
-const INC = 57357
-const DEC = 57358
-const ADDEQ = 57359
-const SUBEQ = 57360
-const MULEQ = 57361
-const DIVEQ = 57362
-const MODEQ = 57363
-const CONCATEQ = 57364
-const FUNC = 57365
-const RETURN = 57366
-const OROR = 57367
-const ANDAND = 57368
-const EQ = 57369
-const NE = 57370
-const LE = 57371
-const GE = 57372
-const NOTMATCH = 57373
+const BEGIN = 57351
+const END = 57352
+const IF = 57353
+const ELSE = 57354
+const SWITCH = 57355
+const CASE = 57356
+const DEFAULT = 57357
+const FALLTHROUGH = 57358
+const FOR = 57359
+const IN = 57360
+const BREAK = 57361
+const CONTINUE = 57362
+const DO = 57363
+const WHILE = 57364
+const INC = 57365
+const DEC = 57366
+const ADDEQ = 57367
+const SUBEQ = 57368
+const MULEQ = 57369
+const DIVEQ = 57370
+const MODEQ = 57371
+const CONCATEQ = 57372
+const POWEQ = 57373
+const ANDEQ = 57374
+const OREQ = 57375
+const XOREQ = 57376
+const ANDNOTEQ = 57377
+const SHLEQ = 57378
+const SHREQ = 57379
+const PIPE = 57380
+const FUNC = 57381
+const RETURN = 57382
+const OROR = 57383
+const ANDAND = 57384
+const EQ = 57385
+const NE = 57386
+const LE = 57387
+const GE = 57388
+const NOTMATCH = 57389
+const SHL = 57390
+const SHR = 57391
+const ANDNOT = 57392
+const UNARY = 57393
+const POW = 57394
 
 var yyToknames = [...]string{
 	"$end",
@@ -77,12 +94,20 @@ var yyToknames = [...]string{
 	"STRING",
 	"PRINT",
 	"NUM",
+	"BEGIN",
+	"END",
 	"IF",
 	"ELSE",
+	"SWITCH",
+	"CASE",
+	"DEFAULT",
+	"FALLTHROUGH",
 	"FOR",
 	"IN",
 	"BREAK",
 	"CONTINUE",
+	"DO",
+	"WHILE",
 	"INC",
 	"DEC",
 	"ADDEQ",
@@ -91,8 +116,17 @@ var yyToknames = [...]string{
 	"DIVEQ",
 	"MODEQ",
 	"CONCATEQ",
+	"POWEQ",
+	"ANDEQ",
+	"OREQ",
+	"XOREQ",
+	"ANDNOTEQ",
+	"SHLEQ",
+	"SHREQ",
+	"PIPE",
 	"FUNC",
 	"RETURN",
+	"'='",
 	"'?'",
 	"':'",
 	"OROR",
@@ -108,21 +142,27 @@ var yyToknames = [...]string{
 	"'.'",
 	"'+'",
 	"'-'",
+	"'|'",
+	"'^'",
 	"'*'",
 	"'/'",
 	"'%'",
-	"';'",
+	"SHL",
+	"SHR",
+	"'&'",
+	"ANDNOT",
+	"UNARY",
+	"POW",
+	"'['",
+	"'$'",
 	"'('",
+	"';'",
 	"')'",
 	"','",
 	"'{'",
 	"'}'",
-	"'|'",
-	"'='",
-	"'['",
-	"']'",
-	"'$'",
 	"'!'",
+	"']'",
 }
 
 var yyStatenames = [...]string{}
@@ -131,244 +171,283 @@ const yyEofCode = 1
 const yyErrCode = 2
 const yyInitialStackSize = 16
 
-//line hawk.y:478
+//line hawk.y:678
 
-// Compile compiles a Hawk program (name) from src. It is not safe
-// for concurrent use.
+// Compile compiles a Hawk program (name) from src.
 func Compile(name string, src io.Reader) (*Program, error) {
-	progName = name
 	sc := new(scan.Scanner)
-	ast = NewProgram(sc)
-	lexlineno = 1
-	nlsemi = false
-	l := &yyLex{reader: bufio.NewReader(src)}
+	prog := NewProgram(sc)
+	l := &yyLex{
+		reader: bufio.NewReader(src),
+		name:   name,
+		lineno: 1,
+		prog:   prog,
+	}
 	yyParse(l)
-	analyse(ast, sc)
-	return ast, l.err
+	if err := analyse(prog, sc); l.err == nil {
+		l.err = err
+	}
+	return prog, l.err
 }
 
 //line yacctab:1
-var yyExca = [...]int{
+var yyExca = [...]int16{
 	-1, 1,
 	1, -1,
 	-2, 0,
-	-1, 17,
-	15, 38,
-	16, 38,
-	17, 38,
-	18, 38,
-	19, 38,
-	20, 38,
-	21, 38,
-	22, 38,
-	50, 38,
-	-2, 79,
-	-1, 18,
-	15, 39,
-	16, 39,
-	17, 39,
-	18, 39,
-	19, 39,
-	20, 39,
-	21, 39,
-	22, 39,
-	50, 39,
-	-2, 84,
-	-1, 29,
-	47, 70,
-	-2, 40,
-	-1, 82,
-	15, 38,
-	16, 38,
-	17, 38,
-	18, 38,
-	19, 38,
-	20, 38,
-	21, 38,
-	22, 38,
-	50, 38,
-	-2, 79,
-	-1, 84,
-	47, 71,
-	-2, 18,
+	-1, 98,
+	74, 104,
+	-2, 36,
+	-1, 172,
+	74, 105,
+	-2, 22,
 }
 
 const yyPrivate = 57344
 
-const yyLast = 540
-
-var yyAct = [...]int{
-	15, 6, 19, 13, 72, 151, 80, 127, 135, 71,
-	14, 46, 47, 48, 49, 50, 51, 58, 59, 57,
-	52, 53, 54, 55, 56, 73, 77, 143, 70, 79,
-	84, 76, 70, 83, 81, 126, 140, 88, 77, 69,
-	42, 132, 93, 14, 96, 97, 98, 99, 100, 101,
-	102, 103, 104, 105, 106, 107, 108, 109, 110, 111,
-	112, 113, 114, 115, 116, 117, 118, 119, 75, 90,
-	121, 77, 125, 70, 8, 133, 7, 18, 137, 138,
-	69, 129, 136, 131, 58, 59, 57, 52, 53, 54,
-	55, 56, 28, 52, 53, 54, 55, 56, 18, 156,
-	8, 39, 123, 57, 52, 53, 54, 55, 56, 43,
-	18, 44, 45, 46, 47, 48, 49, 50, 51, 58,
-	59, 57, 52, 53, 54, 55, 56, 121, 125, 145,
-	8, 73, 142, 149, 128, 148, 144, 145, 161, 152,
-	154, 155, 130, 41, 157, 54, 55, 56, 12, 160,
-	159, 162, 17, 32, 31, 25, 30, 28, 9, 29,
-	147, 22, 23, 163, 165, 83, 166, 167, 164, 26,
-	95, 3, 24, 17, 32, 31, 25, 30, 28, 153,
-	29, 38, 22, 23, 150, 92, 33, 34, 10, 40,
-	94, 1, 36, 24, 11, 8, 21, 78, 20, 37,
-	158, 27, 35, 85, 86, 87, 146, 33, 34, 16,
-	2, 91, 5, 36, 4, 0, 0, 0, 0, 0,
-	37, 0, 27, 35, 0, 0, 0, 0, 0, 43,
-	18, 44, 45, 46, 47, 48, 49, 50, 51, 58,
-	59, 57, 52, 53, 54, 55, 56, 0, 82, 32,
-	31, 25, 30, 28, 0, 29, 141, 22, 23, 0,
-	0, 0, 0, 0, 0, 0, 0, 0, 24, 0,
+const yyLast = 864
+
+var yyAct = [...]uint8{
+	108, 8, 84, 9, 158, 82, 83, 107, 168, 28,
+	29, 30, 86, 76, 78, 79, 80, 85, 81, 155,
+	180, 102, 137, 216, 223, 224, 201, 105, 8, 96,
+	9, 190, 112, 113, 114, 115, 116, 117, 118, 119,
+	120, 121, 122, 123, 124, 125, 126, 127, 128, 129,
+	130, 131, 132, 133, 134, 135, 90, 11, 139, 140,
+	141, 142, 143, 144, 145, 146, 147, 148, 149, 150,
+	151, 152, 89, 88, 87, 153, 240, 16, 235, 103,
+	104, 204, 185, 157, 109, 220, 195, 193, 198, 186,
+	185, 207, 16, 181, 242, 164, 159, 166, 164, 172,
+	225, 196, 173, 165, 55, 171, 193, 27, 193, 75,
+	56, 71, 72, 178, 163, 136, 45, 167, 169, 31,
+	160, 32, 33, 34, 35, 36, 37, 38, 39, 53,
+	54, 52, 40, 41, 47, 48, 42, 43, 44, 50,
+	51, 46, 49, 199, 45, 42, 43, 44, 50, 51,
+	46, 49, 231, 45, 187, 188, 74, 176, 75, 184,
+	85, 178, 3, 97, 200, 191, 227, 98, 182, 194,
+	56, 99, 197, 175, 174, 177, 239, 209, 189, 192,
+	213, 205, 202, 138, 203, 162, 161, 100, 1, 164,
+	111, 210, 222, 221, 202, 91, 217, 164, 208, 212,
+	73, 214, 2, 24, 5, 4, 0, 215, 206, 0,
+	0, 0, 219, 0, 0, 226, 211, 0, 0, 0,
+	0, 0, 218, 85, 0, 0, 85, 0, 233, 0,
+	0, 229, 171, 234, 232, 237, 238, 0, 236, 0,
+	0, 228, 0, 31, 241, 32, 33, 34, 35, 36,
+	37, 38, 39, 53, 54, 52, 40, 41, 47, 48,
+	42, 43, 44, 50, 51, 46, 49, 0, 45, 101,
+	19, 18, 95, 17, 0, 16, 96, 0, 97, 0,
+	0, 0, 98, 0, 92, 93, 99, 0, 21, 22,
+	0, 0, 0, 101, 19, 18, 95, 17, 0, 0,
+	96, 0, 97, 0, 77, 94, 98, 0, 92, 93,
+	99, 0, 21, 22, 0, 0, 0, 0, 0, 0,
+	12, 13, 0, 15, 0, 0, 0, 0, 77, 94,
+	0, 0, 0, 25, 26, 20, 23, 19, 18, 16,
+	17, 14, 0, 0, 12, 13, 0, 15, 0, 0,
+	0, 0, 0, 0, 0, 21, 22, 25, 26, 20,
+	170, 19, 18, 95, 17, 14, 0, 96, 0, 97,
+	0, 77, 0, 98, 0, 92, 93, 99, 0, 21,
+	22, 0, 0, 0, 0, 0, 0, 12, 13, 0,
+	15, 0, 0, 0, 0, 77, 94, 0, 0, 0,
+	25, 26, 20, 0, 0, 0, 0, 0, 14, 154,
+	0, 12, 13, 0, 15, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 25, 26, 20, 0, 0, 0,
+	0, 31, 14, 32, 33, 34, 35, 36, 37, 38,
+	39, 53, 54, 52, 40, 41, 47, 48, 42, 43,
+	44, 50, 51, 46, 49, 0, 45, 0, 23, 19,
+	18, 179, 17, 6, 7, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 21, 22, 53,
+	54, 52, 40, 41, 47, 48, 42, 43, 44, 50,
+	51, 46, 49, 10, 45, 52, 40, 41, 47, 48,
+	42, 43, 44, 50, 51, 46, 49, 0, 45, 12,
+	13, 0, 15, 0, 0, 0, 0, 0, 0, 0,
+	0, 0, 25, 26, 20, 0, 0, 0, 16, 0,
+	14, 31, 183, 32, 33, 34, 35, 36, 37, 38,
+	39, 53, 54, 52, 40, 41, 47, 48, 42, 43,
+	44, 50, 51, 46, 49, 0, 45, 23, 19, 18,
+	0, 17, 23, 19, 18, 0, 17, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 21, 22, 0, 0,
+	0, 21, 22, 0, 0, 0, 0, 0, 0, 0,
+	0, 0, 77, 0, 0, 0, 0, 77, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 12, 13,
+	230, 15, 0, 12, 13, 0, 15, 0, 0, 0,
+	0, 25, 26, 20, 0, 0, 25, 26, 20, 14,
+	106, 0, 0, 31, 14, 32, 33, 34, 35, 36,
+	37, 38, 39, 53, 54, 52, 40, 41, 47, 48,
+	42, 43, 44, 50, 51, 46, 49, 0, 45, 23,
+	19, 18, 0, 17, 23, 19, 18, 0, 17, 23,
+	19, 18, 0, 17, 0, 0, 0, 0, 21, 22,
+	0, 0, 0, 21, 22, 0, 0, 0, 21, 22,
+	0, 0, 0, 0, 77, 0, 0, 0, 0, 77,
+	0, 0, 0, 0, 77, 0, 0, 0, 0, 0,
+	12, 13, 0, 15, 0, 12, 13, 0, 15, 0,
+	0, 110, 0, 25, 26, 20, 0, 156, 25, 26,
+	20, 14, 0, 25, 26, 20, 14, 33, 34, 35,
+	36, 37, 38, 39, 53, 54, 52, 40, 41, 47,
+	48, 42, 43, 44, 50, 51, 46, 49, 0, 45,
+	34, 35, 36, 37, 38, 39, 53, 54, 52, 40,
+	41, 47, 48, 42, 43, 44, 50, 51, 46, 49,
+	0, 45, 71, 72, 58, 59, 60, 61, 62, 63,
+	64, 65, 66, 67, 68, 69, 70, 23, 19, 18,
+	57, 17, 40, 41, 47, 48, 42, 43, 44, 50,
+	51, 46, 49, 0, 45, 0, 21, 22, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 74, 0, 75,
+	0, 0, 77, 0, 0, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
 	0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
-	0, 0, 33, 34, 0, 74, 32, 31, 36, 30,
-	0, 0, 0, 0, 0, 37, 0, 27, 35, 43,
-	0, 44, 45, 46, 47, 48, 49, 50, 51, 58,
-	59, 57, 52, 53, 54, 55, 56, 0, 0, 33,
-	34, 8, 74, 32, 31, 36, 30, 0, 0, 0,
-	0, 0, 37, 124, 27, 35, 0, 0, 0, 74,
-	32, 31, 0, 30, 0, 0, 0, 0, 0, 0,
-	74, 32, 31, 0, 30, 0, 33, 34, 0, 0,
-	0, 0, 36, 122, 0, 0, 0, 0, 0, 37,
-	0, 27, 35, 33, 34, 0, 74, 32, 31, 36,
-	30, 0, 0, 0, 33, 34, 37, 120, 27, 35,
-	36, 0, 0, 0, 0, 0, 0, 37, 89, 27,
-	35, 0, 0, 0, 0, 0, 0, 0, 0, 0,
-	33, 34, 0, 0, 0, 0, 36, 74, 32, 31,
-	0, 30, 0, 37, 0, 27, 35, 43, 0, 44,
-	45, 46, 47, 48, 49, 50, 51, 58, 59, 57,
-	52, 53, 54, 55, 56, 0, 0, 134, 0, 0,
-	0, 33, 34, 0, 0, 0, 0, 36, 0, 0,
-	0, 0, 0, 0, 37, 0, 0, 35, 43, 139,
-	44, 45, 46, 47, 48, 49, 50, 51, 58, 59,
-	57, 52, 53, 54, 55, 56, 43, 0, 44, 45,
-	46, 47, 48, 49, 50, 51, 58, 59, 57, 52,
-	53, 54, 55, 56, 67, 68, 61, 62, 63, 64,
-	65, 66, 45, 46, 47, 48, 49, 50, 51, 58,
-	59, 57, 52, 53, 54, 55, 56, 0, 0, 0,
-	0, 0, 0, 0, 0, 0, 0, 0, 0, 60,
+	0, 25, 26, 20,
 }
 
-var yyPact = [...]int{
-	53, -1000, 115, -1000, -1000, -1000, -1000, 184, 148, 53,
-	57, 100, -9, -1000, -1000, 461, 489, -12, -42, -1000,
-	-1000, -1000, -1000, -1000, 372, 372, -1000, 413, 372, 244,
-	-1000, -1000, -1000, 413, 413, 413, 372, 346, -1000, 181,
-	-6, 148, 164, 372, 372, 372, 372, 372, 372, 372,
-	372, 372, 372, 372, 372, 372, 372, 372, 372, 372,
-	372, 372, 372, 372, 372, 372, 372, -1000, -1000, 335,
-	318, 281, -1000, 461, -16, -44, 88, 461, -1000, 274,
-	99, 27, 29, -1000, 461, -1000, -1000, -1000, 402, -1000,
-	36, 33, -1000, -1000, -9, -1000, 443, 484, -18, 49,
-	49, 49, 49, 49, 49, 105, 105, -1000, -1000, -1000,
-	55, 66, 66, 461, 461, 461, 461, 461, 461, 461,
-	-14, 204, -1000, 36, -23, 84, 372, 372, 372, 150,
-	372, -1000, 372, 180, -1000, -47, 372, 27, 175, 372,
-	372, -1000, 54, 372, -1000, 461, -1000, 83, 95, 274,
-	151, -1000, -1000, -1000, 461, 461, -1000, 461, -1000, -1000,
-	-1000, 169, -1000, 372, 27, 274, -1000, -1000,
+var yyPact = [...]int16{
+	454, -1000, 36, -1000, -1000, -1000, 3, 3, 201, -1000,
+	100, 759, 660, 660, 660, 660, 265, -1000, -1000, -1000,
+	660, 793, 793, -1000, 3, 553, 665, 454, -1000, -1000,
+	-1000, 660, 660, 660, 660, 660, 660, 660, 660, 660,
+	660, 660, 660, 660, 660, 660, 660, 660, 660, 660,
+	660, 660, 660, 660, 660, 45, 179, 660, 660, 660,
+	660, 660, 660, 660, 660, 660, 660, 660, 660, 660,
+	660, -1000, -1000, -1000, 332, 655, 49, 40, 49, 49,
+	49, 25, 82, -1000, -1000, 591, -1000, -1000, -1000, -1000,
+	-1000, -1000, 182, 181, 660, 660, 660, 660, 356, 3,
+	150, 118, 389, 88, 88, -1000, -1000, 20, 591, 39,
+	793, -1000, 489, 692, 714, 427, 427, 427, 427, 427,
+	427, 86, 86, 49, 49, 49, 49, 49, 86, 86,
+	49, 49, 49, 747, 441, 441, 179, 17, -1000, 591,
+	591, 591, 591, 591, 591, 591, 591, 591, 591, 591,
+	591, 591, 591, 77, -1000, 112, -1000, 20, -44, 265,
+	173, -1000, -1000, -1000, 591, 14, 201, 12, 30, 3,
+	70, -1000, 591, 142, -1000, -1000, -1000, -1000, -1000, -1000,
+	-51, 660, 39, 660, 9, 177, -1000, -1000, 660, 19,
+	-1000, 82, -1000, 660, 165, -1000, 660, -1000, 660, 176,
+	660, -1000, 591, 591, 3, -1000, -54, -1000, -1000, 18,
+	10, 29, 201, 148, 591, -1000, -1000, -1000, -1000, -1000,
+	-1000, -1000, 265, 558, 109, 289, -1000, 660, 25, 35,
+	660, -1000, 3, 201, 160, -1000, 33, -1000, -1000, 23,
+	-1000, -1000, -1000,
 }
 
-var yyPgo = [...]int{
-	0, 171, 214, 212, 211, 210, 0, 4, 169, 68,
-	209, 31, 148, 3, 6, 2, 206, 200, 198, 196,
-	1, 194, 191, 189, 8,
+var yyPgo = [...]uint8{
+	0, 162, 205, 204, 22, 203, 202, 0, 19, 57,
+	7, 200, 5, 6, 8, 12, 198, 196, 74, 73,
+	72, 56, 195, 2, 193, 192, 191, 18, 188, 4,
+	187, 20,
 }
 
-var yyR1 = [...]int{
-	0, 22, 5, 5, 1, 1, 2, 3, 4, 4,
-	4, 20, 21, 21, 21, 12, 12, 12, 13, 13,
-	13, 13, 13, 13, 13, 13, 13, 13, 13, 13,
-	13, 13, 13, 13, 13, 13, 13, 13, 10, 10,
-	14, 14, 15, 16, 16, 17, 17, 18, 18, 19,
-	19, 6, 6, 6, 6, 6, 6, 6, 6, 6,
-	6, 6, 6, 6, 6, 6, 6, 6, 6, 6,
-	7, 7, 8, 8, 8, 8, 8, 8, 8, 8,
-	8, 8, 8, 8, 8, 9, 9, 11, 11, 23,
-	23, 24, 24,
+var yyR1 = [...]int8{
+	0, 28, 6, 6, 1, 1, 2, 2, 2, 2,
+	2, 3, 4, 4, 4, 23, 27, 27, 27, 12,
+	12, 12, 13, 13, 13, 13, 13, 13, 13, 13,
+	13, 13, 13, 13, 13, 13, 14, 14, 15, 16,
+	16, 17, 17, 18, 26, 26, 24, 24, 25, 25,
+	25, 19, 19, 20, 20, 21, 22, 22, 22, 22,
+	30, 7, 7, 7, 7, 7, 7, 7, 7, 7,
+	7, 7, 7, 7, 7, 7, 7, 7, 7, 7,
+	7, 7, 7, 7, 7, 7, 7, 7, 7, 7,
+	7, 7, 7, 7, 7, 7, 7, 7, 7, 7,
+	7, 7, 7, 7, 8, 8, 9, 9, 9, 9,
+	9, 9, 9, 9, 9, 9, 9, 9, 9, 9,
+	9, 9, 9, 9, 5, 11, 11, 10, 10, 29,
+	29, 31, 31,
 }
 
-var yyR2 = [...]int{
-	0, 2, 1, 3, 1, 1, 1, 6, 0, 1,
-	3, 4, 0, 1, 3, 1, 1, 3, 1, 3,
-	5, 5, 3, 3, 3, 3, 3, 3, 2, 2,
-	1, 1, 1, 1, 1, 2, 2, 1, 1, 1,
-	0, 1, 4, 0, 2, 1, 1, 7, 3, 5,
-	7, 1, 5, 2, 3, 3, 3, 3, 3, 3,
+var yyR2 = [...]int8{
+	0, 2, 1, 3, 1, 1, 2, 2, 1, 1,
+	2, 6, 0, 1, 3, 4, 0, 1, 3, 1,
+	1, 3, 1, 1, 1, 1, 1, 1, 1, 1,
+	2, 1, 2, 2, 2, 1, 0, 1, 4, 0,
+	2, 1, 1, 5, 0, 2, 3, 5, 3, 4,
+	2, 7, 3, 5, 7, 4, 2, 2, 2, 2,
+	2, 1, 3, 3, 3, 3, 3, 3, 3, 3,
+	3, 3, 3, 3, 3, 3, 5, 3, 3, 3,
 	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
-	0, 1, 1, 1, 1, 2, 2, 2, 3, 1,
-	3, 5, 2, 4, 1, 4, 4, 1, 3, 0,
+	3, 3, 3, 3, 3, 3, 3, 2, 2, 2,
+	2, 3, 3, 3, 0, 1, 1, 1, 1, 3,
+	2, 2, 2, 2, 1, 2, 2, 2, 4, 4,
+	3, 6, 2, 3, 4, 2, 4, 1, 3, 0,
 	1, 0, 1,
 }
 
-var yyChk = [...]int{
-	-1000, -22, -5, -1, -2, -3, -20, 23, 47, 43,
-	4, -21, -12, -13, -20, -6, -10, 4, -9, -15,
-	-18, -19, 13, 14, 24, 7, -8, 53, 9, 11,
-	8, 6, 5, 38, 39, 54, 44, 51, -1, 44,
-	-23, 43, 49, 25, 27, 28, 29, 30, 31, 32,
-	33, 34, 38, 39, 40, 41, 42, 37, 35, 36,
-	50, 17, 18, 19, 20, 21, 22, 15, 16, 51,
-	44, 51, -7, -6, 4, -9, -11, -6, -8, -6,
-	-14, -7, 4, -13, -6, -8, -8, -8, -6, 52,
-	-11, -4, 4, 48, -12, 6, -6, -6, -6, -6,
-	-6, -6, -6, -6, -6, -6, -6, -6, -6, -6,
-	-6, -6, -6, -6, -6, -6, -6, -6, -6, -6,
-	52, -6, 45, -11, 52, -6, 51, 51, 46, -20,
-	43, -20, 12, 46, 45, -24, 46, 45, 46, 26,
-	50, 52, -24, 50, 52, -6, -16, 10, -7, -6,
-	4, 52, -20, 4, -6, -6, 45, -6, -17, -15,
-	-20, 43, -20, 12, -14, -6, -20, -20,
+var yyChk = [...]int16{
+	-1000, -28, -6, -1, -2, -3, 9, 10, -7, -23,
+	39, -9, 55, 56, 76, 58, 74, 8, 6, 5,
+	70, 23, 24, 4, -5, 68, 69, 71, -23, -23,
+	-23, 42, 44, 45, 46, 47, 48, 49, 50, 51,
+	55, 56, 59, 60, 61, 67, 64, 57, 58, 65,
+	62, 63, 54, 52, 53, 4, 70, 41, 25, 26,
+	27, 28, 29, 30, 31, 32, 33, 34, 35, 36,
+	37, 23, 24, -11, 68, 70, -7, 39, -7, -7,
+	-7, -27, -12, -13, -23, -7, -15, -18, -19, -20,
+	-21, -22, 19, 20, 40, 7, 11, 13, 17, 21,
+	-30, 4, -7, -9, -9, -23, 77, -10, -7, -9,
+	56, -1, -7, -7, -7, -7, -7, -7, -7, -7,
+	-7, -7, -7, -7, -7, -7, -7, -7, -7, -7,
+	-7, -7, -7, -7, -7, -7, 70, -4, 4, -7,
+	-7, -7, -7, -7, -7, -7, -7, -7, -7, -7,
+	-7, -7, -7, -7, 77, -8, 72, -10, -29, 71,
+	38, 4, 4, -8, -7, -10, -7, -8, -14, -8,
+	4, -13, -7, -23, -19, -20, -21, -18, 43, 72,
+	-31, 73, -9, 43, -4, 73, 72, 77, 43, -31,
+	75, -12, 6, 73, -23, 74, 71, -23, 18, 73,
+	22, 77, -7, -7, 72, 4, -8, 72, -16, 12,
+	-26, -8, -7, 4, -7, -23, 77, -17, -15, -23,
+	75, -24, -25, 14, 15, 71, -23, 18, -27, -10,
+	52, 43, -14, -7, -29, 43, -10, -23, -23, 16,
+	43, -29, 71,
 }
 
-var yyDef = [...]int{
-	0, -2, 0, 2, 4, 5, 6, 0, 12, 1,
-	0, 89, 13, 15, 16, 18, 0, -2, -2, 30,
-	31, 32, 33, 34, 70, 37, 51, 0, 0, -2,
-	72, 73, 74, 0, 0, 0, 0, 0, 3, 8,
-	0, 90, 0, 0, 0, 0, 0, 0, 0, 0,
+var yyDef = [...]int16{
+	0, -2, 0, 2, 4, 5, 0, 0, 8, 9,
+	0, 61, 0, 0, 0, 0, 16, 106, 107, 108,
+	0, 0, 0, 114, 0, 0, 0, 1, 6, 7,
+	10, 0, 0, 0, 0, 0, 0, 0, 0, 0,
 	0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
-	0, 0, 0, 0, 0, 0, 0, 28, 29, 0,
-	0, 0, 35, 71, 79, 84, 36, 87, 53, 0,
-	0, 0, -2, 41, -2, 75, 76, 77, 0, 82,
-	91, 0, 9, 11, 14, 17, 0, 54, 55, 56,
-	57, 58, 59, 60, 61, 62, 63, 64, 65, 66,
-	67, 68, 69, 19, 22, 23, 24, 25, 26, 27,
-	0, 0, 80, 91, 0, 0, 0, 0, 0, 43,
-	70, 48, 0, 0, 78, 0, 92, 0, 0, 0,
-	0, 85, 0, 0, 86, 88, 42, 0, 0, 0,
-	0, 83, 7, 10, 52, 20, 81, 21, 44, 45,
-	46, 40, 49, 0, 0, 0, 47, 50,
+	0, 0, 0, 0, 0, 0, 12, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	0, 110, 111, 115, 104, 0, 97, 0, 98, 99,
+	100, 129, 17, 19, 20, 22, 23, 24, 25, 26,
+	27, 28, 29, 31, 104, 35, 0, 104, -2, 0,
+	0, 114, 0, 112, 113, 116, 117, 131, 127, 122,
+	0, 3, 0, 77, 78, 79, 80, 81, 82, 83,
+	84, 85, 86, 87, 88, 89, 90, 91, 92, 93,
+	94, 95, 96, 101, 102, 103, 12, 0, 13, 62,
+	63, 64, 65, 66, 67, 68, 69, 70, 71, 72,
+	73, 74, 75, 105, 120, 0, 125, 131, 0, 130,
+	0, 30, 32, 33, 105, 34, 0, 0, 0, 0,
+	114, 37, -2, 0, 56, 57, 58, 59, 60, 109,
+	0, 132, 123, 0, 0, 0, 124, 119, 104, 0,
+	15, 18, 21, 0, 39, 44, 104, 52, 0, 0,
+	0, 118, 128, 76, 0, 14, 0, 126, 38, 0,
+	0, 0, 0, 0, 55, 11, 121, 40, 41, 42,
+	43, 45, 16, 0, 0, 36, 53, 0, 129, 0,
+	0, 50, 0, 0, 46, 48, 0, 51, 54, 129,
+	49, 47, 130,
 }
 
-var yyTok1 = [...]int{
+var yyTok1 = [...]int8{
 	1, 3, 3, 3, 3, 3, 3, 3, 3, 3,
 	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
 	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
-	3, 3, 3, 54, 3, 3, 53, 42, 3, 3,
-	44, 45, 40, 38, 46, 39, 37, 41, 3, 3,
-	3, 3, 3, 3, 3, 3, 3, 3, 26, 43,
-	33, 50, 34, 25, 3, 3, 3, 3, 3, 3,
+	3, 3, 3, 76, 3, 3, 69, 61, 64, 3,
+	70, 72, 59, 55, 73, 56, 54, 60, 3, 3,
+	3, 3, 3, 3, 3, 3, 3, 3, 43, 71,
+	50, 41, 51, 42, 3, 3, 3, 3, 3, 3,
 	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
 	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
-	3, 51, 3, 52, 3, 3, 3, 3, 3, 3,
+	3, 68, 3, 77, 58, 3, 3, 3, 3, 3,
 	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
 	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
-	3, 3, 3, 47, 49, 48, 35,
+	3, 3, 3, 74, 57, 75, 52,
 }
 
-var yyTok2 = [...]int{
+var yyTok2 = [...]int8{
 	2, 3, 4, 5, 6, 7, 8, 9, 10, 11,
 	12, 13, 14, 15, 16, 17, 18, 19, 20, 21,
-	22, 23, 24, 27, 28, 29, 30, 31, 32, 36,
+	22, 23, 24, 25, 26, 27, 28, 29, 30, 31,
+	32, 33, 34, 35, 36, 37, 38, 39, 40, 44,
+	45, 46, 47, 48, 49, 53, 62, 63, 65, 66,
+	67,
 }
 
-var yyTok3 = [...]int{
+var yyTok3 = [...]int8{
 	0,
 }
 
@@ -450,9 +529,9 @@ func yyErrorMessage(state, lookAhead int) string {
 	expected := make([]int, 0, 4)
 
 	// Look for shiftable tokens.
-	base := yyPact[state]
+	base := int(yyPact[state])
 	for tok := TOKSTART; tok-1 < len(yyToknames); tok++ {
-		if n := base + tok; n >= 0 && n < yyLast && yyChk[yyAct[n]] == tok {
+		if n := base + tok; n >= 0 && n < yyLast && int(yyChk[int(yyAct[n])]) == tok {
 			if len(expected) == cap(expected) {
 				return res
 			}
@@ -462,13 +541,13 @@ func yyErrorMessage(state, lookAhead int) string {
 
 	if yyDef[state] == -2 {
 		i := 0
-		for yyExca[i] != -1 || yyExca[i+1] != state {
+		for yyExca[i] != -1 || int(yyExca[i+1]) != state {
 			i += 2
 		}
 
 		// Look for tokens that we accept or reduce.
 		for i += 2; yyExca[i] >= 0; i += 2 {
-			tok := yyExca[i]
+			tok := int(yyExca[i])
 			if tok < TOKSTART || yyExca[i+1] == 0 {
 				continue
 			}
@@ -499,30 +578,30 @@ func yylex1(lex yyLexer, lval *yySymType) (char, token int) {
 	token = 0
 	char = lex.Lex(lval)
 	if char <= 0 {
-		token = yyTok1[0]
+		token = int(yyTok1[0])
 		goto out
 	}
 	if char < len(yyTok1) {
-		token = yyTok1[char]
+		token = int(yyTok1[char])
 		goto out
 	}
 	if char >= yyPrivate {
 		if char < yyPrivate+len(yyTok2) {
-			token = yyTok2[char-yyPrivate]
+			token = int(yyTok2[char-yyPrivate])
 			goto out
 		}
 	}
 	for i := 0; i < len(yyTok3); i += 2 {
-		token = yyTok3[i+0]
+		token = int(yyTok3[i+0])
 		if token == char {
-			token = yyTok3[i+1]
+			token = int(yyTok3[i+1])
 			goto out
 		}
 	}
 
 out:
 	if token == 0 {
-		token = yyTok2[1] /* unknown char */
+		token = int(yyTok2[1]) /* unknown char */
 	}
 	if yyDebug >= 3 {
 		__yyfmt__.Printf("lex %s(%d)\n", yyTokname(token), uint(char))
@@ -577,7 +656,7 @@ yystack:
 	yyS[yyp].yys = yystate
 
 yynewstate:
-	yyn = yyPact[yystate]
+	yyn = int(yyPact[yystate])
 	if yyn <= yyFlag {
 		goto yydefault /* simple state */
 	}
@@ -588,8 +667,8 @@ yynewstate:
 	if yyn < 0 || yyn >= yyLast {
 		goto yydefault
 	}
-	yyn = yyAct[yyn]
-	if yyChk[yyn] == yytoken { /* valid shift */
+	yyn = int(yyAct[yyn])
+	if int(yyChk[yyn]) == yytoken { /* valid shift */
 		yyrcvr.char = -1
 		yytoken = -1
 		yyVAL = yyrcvr.lval
@@ -602,7 +681,7 @@ yynewstate:
 
 yydefault:
 	/* default state action */
-	yyn = yyDef[yystate]
+	yyn = int(yyDef[yystate])
 	if yyn == -2 {
 		if yyrcvr.char < 0 {
 			yyrcvr.char, yytoken = yylex1(yylex, &yyrcvr.lval)
@@ -611,18 +690,18 @@ yydefault:
 		/* look through exception table */
 		xi := 0
 		for {
-			if yyExca[xi+0] == -1 && yyExca[xi+1] == yystate {
+			if yyExca[xi+0] == -1 && int(yyExca[xi+1]) == yystate {
 				break
 			}
 			xi += 2
 		}
 		for xi += 2; ; xi += 2 {
-			yyn = yyExca[xi+0]
+			yyn = int(yyExca[xi+0])
 			if yyn < 0 || yyn == yytoken {
 				break
 			}
 		}
-		yyn = yyExca[xi+1]
+		yyn = int(yyExca[xi+1])
 		if yyn < 0 {
 			goto ret0
 		}
@@ -644,10 +723,10 @@ yydefault:
 
 			/* find a state where "error" is a legal shift action */
 			for yyp >= 0 {
-				yyn = yyPact[yyS[yyp].yys] + yyErrCode
+				yyn = int(yyPact[yyS[yyp].yys]) + yyErrCode
 				if yyn >= 0 && yyn < yyLast {
-					yystate = yyAct[yyn] /* simulate a shift of "error" */
-					if yyChk[yystate] == yyErrCode {
+					yystate = int(yyAct[yyn]) /* simulate a shift of "error" */
+					if int(yyChk[yystate]) == yyErrCode {
 						goto yystack
 					}
 				}
@@ -683,7 +762,7 @@ yydefault:
 	yypt := yyp
 	_ = yypt // guard against "declared and not used"
 
-	yyp -= yyR2[yyn]
+	yyp -= int(yyR2[yyn])
 	// yyp is now the index of $0. Perform the default action. Iff the
 	// reduced production is ε, $1 is possibly out of range.
 	if yyp+1 >= len(yyS) {
@@ -694,16 +773,16 @@ yydefault:
 	yyVAL = yyS[yyp+1]
 
 	/* consult goto table to find next state */
-	yyn = yyR1[yyn]
-	yyg := yyPgo[yyn]
+	yyn = int(yyR1[yyn])
+	yyg := int(yyPgo[yyn])
 	yyj := yyg + yyS[yyp].yys + 1
 
 	if yyj >= yyLast {
-		yystate = yyAct[yyg]
+		yystate = int(yyAct[yyg])
 	} else {
-		yystate = yyAct[yyj]
-		if yyChk[yystate] != -yyn {
-			yystate = yyAct[yyg]
+		yystate = int(yyAct[yyj])
+		if int(yyChk[yystate]) != -yyn {
+			yystate = int(yyAct[yyg])
 		}
 	}
 	// dummy call; replaced with literal code
@@ -711,14 +790,19 @@ yydefault:
 
 	case 1:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line hawk.y:65
+//line hawk.y:72
 		{
+			prog := yylex.(*yyLex).prog
 			for _, d := range yyDollar[1].decllist {
 				switch d := d.(type) {
-				case *Action:
-					ast.Actions = append(ast.Actions, d)
+				case *BeginAction:
+					prog.begins = append(prog.begins, d)
+				case *PatternAction:
+					prog.pActions = append(prog.pActions, d)
+				case *EndAction:
+					prog.ends = append(prog.ends, d)
 				case *FuncDecl:
-					ast.funcs[d.Name] = d
+					prog.funcs[d.Name] = d
 				default:
 					panic(fmt.Sprintf("unexpected type: %T", d))
 				}
@@ -726,523 +810,782 @@ yydefault:
 		}
 	case 2:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line hawk.y:80
+//line hawk.y:92
 		{
 			yyVAL.decllist = []Decl{yyDollar[1].decl}
 		}
 	case 3:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line hawk.y:84
+//line hawk.y:96
 		{
 			yyVAL.decllist = append(yyDollar[1].decllist, yyDollar[3].decl)
 		}
 	case 4:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line hawk.y:90
+//line hawk.y:102
 		{
 			yyVAL.decl = yyDollar[1].decl
 		}
 	case 5:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line hawk.y:94
+//line hawk.y:106
 		{
 			yyVAL.decl = yyDollar[1].decl
 		}
 	case 6:
-		yyDollar = yyS[yypt-1 : yypt+1]
-//line hawk.y:100
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line hawk.y:112
 		{
-			yyVAL.decl = &Action{yyDollar[1].blockstmt}
+			yyVAL.decl = &BeginAction{yyDollar[2].blockstmt}
 		}
 	case 7:
-		yyDollar = yyS[yypt-6 : yypt+1]
-//line hawk.y:106
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line hawk.y:116
 		{
-			yyVAL.decl = &FuncDecl{&FuncScope{}, yyDollar[2].sym, yyDollar[4].symlist, yyDollar[6].blockstmt}
+			yyVAL.decl = &EndAction{yyDollar[2].blockstmt}
 		}
 	case 8:
-		yyDollar = yyS[yypt-0 : yypt+1]
-//line hawk.y:111
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line hawk.y:120
 		{
-			yyVAL.symlist = nil
+			// The default action is { print }.
+			action := &BlockStmt{[]Stmt{&PrintStmt{Fun: "print"}}}
+			yyVAL.decl = &PatternAction{genDebugInfo(yylex), yyDollar[1].expr, action}
 		}
 	case 9:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line hawk.y:115
+//line hawk.y:126
 		{
-			yyVAL.symlist = []string{yyDollar[1].sym}
+			yyVAL.decl = &PatternAction{genDebugInfo(yylex), nil, yyDollar[1].blockstmt}
 		}
 	case 10:
-		yyDollar = yyS[yypt-3 : yypt+1]
-//line hawk.y:119
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line hawk.y:130
 		{
-			yyVAL.symlist = append(yyDollar[1].symlist, yyDollar[3].sym)
+			yyVAL.decl = &PatternAction{genDebugInfo(yylex), yyDollar[1].expr, yyDollar[2].blockstmt}
 		}
 	case 11:
-		yyDollar = yyS[yypt-4 : yypt+1]
-//line hawk.y:125
+		yyDollar = yyS[yypt-6 : yypt+1]
+//line hawk.y:136
 		{
-			yyVAL.blockstmt = &BlockStmt{yyDollar[2].stmtlist}
+			yyVAL.decl = &FuncDecl{genDebugInfo(yylex), &FuncScope{}, yyDollar[2].sym, yyDollar[4].symlist, yyDollar[6].blockstmt, nil}
 		}
 	case 12:
 		yyDollar = yyS[yypt-0 : yypt+1]
-//line hawk.y:130
+//line hawk.y:141
 		{
-			yyVAL.stmtlist = nil
+			yyVAL.symlist = nil
 		}
 	case 13:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line hawk.y:134
+//line hawk.y:145
 		{
-			yyVAL.stmtlist = []Stmt{yyDollar[1].stmt}
+			yyVAL.symlist = []string{yyDollar[1].sym}
 		}
 	case 14:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line hawk.y:138
+//line hawk.y:149
 		{
-			yyVAL.stmtlist = append(yyDollar[1].stmtlist, yyDollar[3].stmt)
+			yyVAL.symlist = append(yyDollar[1].symlist, yyDollar[3].sym)
 		}
 	case 15:
-		yyDollar = yyS[yypt-1 : yypt+1]
-//line hawk.y:144
+		yyDollar = yyS[yypt-4 : yypt+1]
+//line hawk.y:155
 		{
-			yyVAL.stmt = yyDollar[1].stmt
+			yyVAL.blockstmt = &BlockStmt{yyDollar[2].stmtlist}
 		}
 	case 16:
-		yyDollar = yyS[yypt-1 : yypt+1]
-//line hawk.y:148
+		yyDollar = yyS[yypt-0 : yypt+1]
+//line hawk.y:160
 		{
-			yyVAL.stmt = yyDollar[1].blockstmt
+			yyVAL.stmtlist = nil
 		}
 	case 17:
-		yyDollar = yyS[yypt-3 : yypt+1]
-//line hawk.y:152
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line hawk.y:164
 		{
-			yyVAL.stmt = &PipeStmt{genDebugInfo(), yyDollar[1].stmt, yyDollar[3].sym}
+			yyVAL.stmtlist = []Stmt{yyDollar[1].stmt}
 		}
 	case 18:
-		yyDollar = yyS[yypt-1 : yypt+1]
-//line hawk.y:158
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line hawk.y:168
 		{
-			yyVAL.stmt = &ExprStmt{yyDollar[1].expr}
+			yyVAL.stmtlist = append(yyDollar[1].stmtlist, yyDollar[3].stmt)
 		}
 	case 19:
-		yyDollar = yyS[yypt-3 : yypt+1]
-//line hawk.y:162
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line hawk.y:174
 		{
-			yyVAL.stmt = &AssignStmt{genDebugInfo(), nil, yyDollar[1].expr, yyDollar[3].expr}
+			yyVAL.stmt = yyDollar[1].stmt
 		}
 	case 20:
-		yyDollar = yyS[yypt-5 : yypt+1]
-//line hawk.y:169
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line hawk.y:178
 		{
-			yyVAL.stmt = &AssignStmt{genDebugInfo(), nil, &IndexExpr{genDebugInfo(), &Ident{Name: yyDollar[1].sym}, nil}, yyDollar[5].expr}
+			yyVAL.stmt = yyDollar[1].blockstmt
 		}
 	case 21:
-		yyDollar = yyS[yypt-5 : yypt+1]
-//line hawk.y:173
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line hawk.y:182
 		{
-			yyVAL.stmt = &AssignStmt{genDebugInfo(), nil, &IndexExpr{genDebugInfo(), yyDollar[1].expr, nil}, yyDollar[5].expr}
+			yyVAL.stmt = &PipeStmt{genDebugInfo(yylex), yyDollar[1].stmt, yyDollar[3].sym}
 		}
 	case 22:
-		yyDollar = yyS[yypt-3 : yypt+1]
-//line hawk.y:178
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line hawk.y:188
 		{
-			yyVAL.stmt = &AssignStmt{genDebugInfo(), nil, yyDollar[1].expr, &BinaryExpr{genDebugInfo(), Add, yyDollar[1].expr, yyDollar[3].expr}}
+			yyVAL.stmt = &ExprStmt{yyDollar[1].expr}
 		}
 	case 23:
-		yyDollar = yyS[yypt-3 : yypt+1]
-//line hawk.y:182
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line hawk.y:192
 		{
-			yyVAL.stmt = &AssignStmt{genDebugInfo(), nil, yyDollar[1].expr, &BinaryExpr{genDebugInfo(), Sub, yyDollar[1].expr, yyDollar[3].expr}}
+			yyVAL.stmt = yyDollar[1].stmt
 		}
 	case 24:
-		yyDollar = yyS[yypt-3 : yypt+1]
-//line hawk.y:186
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line hawk.y:196
 		{
-			yyVAL.stmt = &AssignStmt{genDebugInfo(), nil, yyDollar[1].expr, &BinaryExpr{genDebugInfo(), Mul, yyDollar[1].expr, yyDollar[3].expr}}
+			yyVAL.stmt = yyDollar[1].stmt
 		}
 	case 25:
-		yyDollar = yyS[yypt-3 : yypt+1]
-//line hawk.y:190
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line hawk.y:200
 		{
-			yyVAL.stmt = &AssignStmt{genDebugInfo(), nil, yyDollar[1].expr, &BinaryExpr{genDebugInfo(), Div, yyDollar[1].expr, yyDollar[3].expr}}
+			yyVAL.stmt = yyDollar[1].stmt
 		}
 	case 26:
-		yyDollar = yyS[yypt-3 : yypt+1]
-//line hawk.y:194
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line hawk.y:204
 		{
-			yyVAL.stmt = &AssignStmt{genDebugInfo(), nil, yyDollar[1].expr, &BinaryExpr{genDebugInfo(), Mod, yyDollar[1].expr, yyDollar[3].expr}}
+			yyVAL.stmt = yyDollar[1].stmt
 		}
 	case 27:
-		yyDollar = yyS[yypt-3 : yypt+1]
-//line hawk.y:198
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line hawk.y:208
 		{
-			yyVAL.stmt = &AssignStmt{genDebugInfo(), nil, yyDollar[1].expr, &BinaryExpr{genDebugInfo(), Concat, yyDollar[1].expr, yyDollar[3].expr}}
+			yyVAL.stmt = yyDollar[1].stmt
 		}
 	case 28:
-		yyDollar = yyS[yypt-2 : yypt+1]
-//line hawk.y:202
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line hawk.y:212
 		{
-			yyVAL.stmt = &AssignStmt{genDebugInfo(), nil, yyDollar[1].expr, &BinaryExpr{genDebugInfo(), Add, yyDollar[1].expr, BasicLit{value.NewNumber(1)}}}
+			yyVAL.stmt = yyDollar[1].stmt
 		}
 	case 29:
-		yyDollar = yyS[yypt-2 : yypt+1]
-//line hawk.y:206
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line hawk.y:216
 		{
-			yyVAL.stmt = &AssignStmt{genDebugInfo(), nil, yyDollar[1].expr, &BinaryExpr{genDebugInfo(), Sub, yyDollar[1].expr, BasicLit{value.NewNumber(1)}}}
+			yyVAL.stmt = &StatusStmt{genDebugInfo(yylex), nil, StatusBreak, ""}
 		}
 	case 30:
-		yyDollar = yyS[yypt-1 : yypt+1]
-//line hawk.y:210
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line hawk.y:220
 		{
-			yyVAL.stmt = yyDollar[1].stmt
+			yyVAL.stmt = &StatusStmt{genDebugInfo(yylex), nil, StatusBreak, yyDollar[2].sym}
+			findLabel(yylex, yyVAL.stmt.(*StatusStmt))
 		}
 	case 31:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line hawk.y:214
+//line hawk.y:225
 		{
-			yyVAL.stmt = yyDollar[1].stmt
+			yyVAL.stmt = &StatusStmt{genDebugInfo(yylex), nil, StatusContinue, ""}
 		}
 	case 32:
-		yyDollar = yyS[yypt-1 : yypt+1]
-//line hawk.y:218
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line hawk.y:229
 		{
-			yyVAL.stmt = yyDollar[1].stmt
+			yyVAL.stmt = &StatusStmt{genDebugInfo(yylex), nil, StatusContinue, yyDollar[2].sym}
+			findLabel(yylex, yyVAL.stmt.(*StatusStmt))
 		}
 	case 33:
-		yyDollar = yyS[yypt-1 : yypt+1]
-//line hawk.y:222
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line hawk.y:234
 		{
-			yyVAL.stmt = &StatusStmt{StatusBreak}
+			yyVAL.stmt = &ReturnStmt{X: yyDollar[2].expr}
 		}
 	case 34:
-		yyDollar = yyS[yypt-1 : yypt+1]
-//line hawk.y:226
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line hawk.y:238
 		{
-			yyVAL.stmt = &StatusStmt{StatusContinue}
+			yyVAL.stmt = &PrintStmt{genDebugInfo(yylex), nil, yyDollar[1].sym, yyDollar[2].exprlist}
 		}
 	case 35:
-		yyDollar = yyS[yypt-2 : yypt+1]
-//line hawk.y:230
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line hawk.y:242
 		{
-			yyVAL.stmt = &ReturnStmt{X: yyDollar[2].expr}
+			yyVAL.stmt = &PrintStmt{genDebugInfo(yylex), nil, yyDollar[1].sym, nil}
 		}
 	case 36:
-		yyDollar = yyS[yypt-2 : yypt+1]
-//line hawk.y:234
+		yyDollar = yyS[yypt-0 : yypt+1]
+//line hawk.y:247
 		{
-			yyVAL.stmt = &PrintStmt{genDebugInfo(), nil, yyDollar[1].sym, yyDollar[2].exprlist}
+			yyVAL.stmt = nil
 		}
 	case 37:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line hawk.y:238
+//line hawk.y:251
 		{
-			yyVAL.stmt = &PrintStmt{genDebugInfo(), nil, yyDollar[1].sym, nil}
+			yyVAL.stmt = yyDollar[1].stmt
 		}
 	case 38:
-		yyDollar = yyS[yypt-1 : yypt+1]
-//line hawk.y:244
+		yyDollar = yyS[yypt-4 : yypt+1]
+//line hawk.y:257
 		{
-			yyVAL.expr = &Ident{ast, yyDollar[1].sym}
+			yyVAL.stmt = &IfStmt{genDebugInfo(yylex), yyDollar[2].expr, yyDollar[3].blockstmt, yyDollar[4].stmt}
 		}
 	case 39:
-		yyDollar = yyS[yypt-1 : yypt+1]
-//line hawk.y:248
+		yyDollar = yyS[yypt-0 : yypt+1]
+//line hawk.y:262
 		{
-			yyVAL.expr = yyDollar[1].expr
+			yyVAL.stmt = nil
 		}
 	case 40:
-		yyDollar = yyS[yypt-0 : yypt+1]
-//line hawk.y:253
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line hawk.y:266
 		{
-			yyVAL.stmt = nil
+			yyVAL.stmt = yyDollar[2].stmt
 		}
 	case 41:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line hawk.y:257
+//line hawk.y:272
 		{
 			yyVAL.stmt = yyDollar[1].stmt
 		}
 	case 42:
-		yyDollar = yyS[yypt-4 : yypt+1]
-//line hawk.y:263
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line hawk.y:276
 		{
-			yyVAL.stmt = &IfStmt{genDebugInfo(), yyDollar[2].expr, yyDollar[3].blockstmt, yyDollar[4].stmt}
+			yyVAL.stmt = yyDollar[1].blockstmt
 		}
 	case 43:
-		yyDollar = yyS[yypt-0 : yypt+1]
-//line hawk.y:268
+		yyDollar = yyS[yypt-5 : yypt+1]
+//line hawk.y:282
 		{
-			yyVAL.stmt = nil
+			yyVAL.stmt = &SwitchStmt{nil, "", yyDollar[2].expr, yyDollar[4].caselist}
+			checkSwitch(yylex, yyVAL.stmt.(*SwitchStmt))
 		}
 	case 44:
-		yyDollar = yyS[yypt-2 : yypt+1]
-//line hawk.y:272
+		yyDollar = yyS[yypt-0 : yypt+1]
+//line hawk.y:288
 		{
-			yyVAL.stmt = yyDollar[2].stmt
+			yyVAL.caselist = nil
 		}
 	case 45:
-		yyDollar = yyS[yypt-1 : yypt+1]
-//line hawk.y:278
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line hawk.y:292
 		{
-			yyVAL.stmt = yyDollar[1].stmt
+			yyVAL.caselist = append(yyDollar[1].caselist, yyDollar[2].caseclause)
 		}
 	case 46:
-		yyDollar = yyS[yypt-1 : yypt+1]
-//line hawk.y:282
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line hawk.y:298
 		{
-			yyVAL.stmt = yyDollar[1].blockstmt
+			yyDollar[1].caseclause.Body = &BlockStmt{yyDollar[2].stmtlist}
+			yyVAL.caseclause = yyDollar[1].caseclause
 		}
 	case 47:
-		yyDollar = yyS[yypt-7 : yypt+1]
-//line hawk.y:288
+		yyDollar = yyS[yypt-5 : yypt+1]
+//line hawk.y:303
 		{
-			yyVAL.stmt = &ForStmt{genDebugInfo(), yyDollar[2].stmt, yyDollar[4].expr, yyDollar[6].stmt, yyDollar[7].blockstmt}
+			yyDollar[1].caseclause.Body = &BlockStmt{yyDollar[2].stmtlist}
+			yyDollar[1].caseclause.Fallthrough = true
+			yyVAL.caseclause = yyDollar[1].caseclause
 		}
 	case 48:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line hawk.y:292
+//line hawk.y:311
 		{
-			yyVAL.stmt = &ForStmt{genDebugInfo(), nil, yyDollar[2].expr, nil, yyDollar[3].blockstmt}
+			yyVAL.caseclause = &CaseClause{debugInfo: genDebugInfo(yylex), Exprs: yyDollar[2].exprlist}
 		}
 	case 49:
-		yyDollar = yyS[yypt-5 : yypt+1]
-//line hawk.y:298
+		yyDollar = yyS[yypt-4 : yypt+1]
+//line hawk.y:315
 		{
-			yyVAL.stmt = &ForeachStmt{genDebugInfo(), &Ident{Name: yyDollar[2].sym}, nil, yyDollar[4].expr, yyDollar[5].blockstmt}
+			yyVAL.caseclause = &CaseClause{debugInfo: genDebugInfo(yylex), Exprs: yyDollar[3].exprlist, Regexp: true}
 		}
 	case 50:
-		yyDollar = yyS[yypt-7 : yypt+1]
-//line hawk.y:302
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line hawk.y:319
 		{
-			yyVAL.stmt = &ForeachStmt{genDebugInfo(), &Ident{Name: yyDollar[2].sym}, &Ident{Name: yyDollar[4].sym}, yyDollar[6].expr, yyDollar[7].blockstmt}
+			yyVAL.caseclause = &CaseClause{debugInfo: genDebugInfo(yylex)}
 		}
 	case 51:
-		yyDollar = yyS[yypt-1 : yypt+1]
-//line hawk.y:309
+		yyDollar = yyS[yypt-7 : yypt+1]
+//line hawk.y:325
 		{
-			yyVAL.expr = yyDollar[1].expr
+			yyVAL.stmt = &ForStmt{genDebugInfo(yylex), nil, "", yyDollar[2].stmt, yyDollar[4].expr, yyDollar[6].stmt, yyDollar[7].blockstmt}
 		}
 	case 52:
-		yyDollar = yyS[yypt-5 : yypt+1]
-//line hawk.y:313
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line hawk.y:329
 		{
-			yyVAL.expr = &TernaryExpr{genDebugInfo(), yyDollar[1].expr, yyDollar[3].expr, yyDollar[5].expr}
+			yyVAL.stmt = &ForStmt{genDebugInfo(yylex), nil, "", nil, yyDollar[2].expr, nil, yyDollar[3].blockstmt}
 		}
 	case 53:
-		yyDollar = yyS[yypt-2 : yypt+1]
-//line hawk.y:317
+		yyDollar = yyS[yypt-5 : yypt+1]
+//line hawk.y:335
 		{
-			yyVAL.expr = &FieldExpr{genDebugInfo(), nil, yyDollar[2].expr}
+			yyVAL.stmt = &ForeachStmt{genDebugInfo(yylex), nil, "", &Ident{Name: yyDollar[2].sym}, nil, yyDollar[4].expr, yyDollar[5].blockstmt}
 		}
 	case 54:
-		yyDollar = yyS[yypt-3 : yypt+1]
-//line hawk.y:321
+		yyDollar = yyS[yypt-7 : yypt+1]
+//line hawk.y:339
 		{
-			yyVAL.expr = &BinaryExpr{genDebugInfo(), OrOr, yyDollar[1].expr, yyDollar[3].expr}
+			yyVAL.stmt = &ForeachStmt{genDebugInfo(yylex), nil, "", &Ident{Name: yyDollar[2].sym}, &Ident{Name: yyDollar[4].sym}, yyDollar[6].expr, yyDollar[7].blockstmt}
 		}
 	case 55:
-		yyDollar = yyS[yypt-3 : yypt+1]
-//line hawk.y:325
+		yyDollar = yyS[yypt-4 : yypt+1]
+//line hawk.y:345
 		{
-			yyVAL.expr = &BinaryExpr{genDebugInfo(), AndAnd, yyDollar[1].expr, yyDollar[3].expr}
+			yyVAL.stmt = &DoStmt{genDebugInfo(yylex), nil, "", yyDollar[2].blockstmt, yyDollar[4].expr}
 		}
 	case 56:
-		yyDollar = yyS[yypt-3 : yypt+1]
-//line hawk.y:329
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line hawk.y:351
 		{
-			yyVAL.expr = &BinaryExpr{genDebugInfo(), Eq, yyDollar[1].expr, yyDollar[3].expr}
+			yyVAL.stmt = popLabel(yylex, yyDollar[2].stmt)
 		}
 	case 57:
-		yyDollar = yyS[yypt-3 : yypt+1]
-//line hawk.y:333
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line hawk.y:355
 		{
-			yyVAL.expr = &BinaryExpr{genDebugInfo(), NotEq, yyDollar[1].expr, yyDollar[3].expr}
+			yyVAL.stmt = popLabel(yylex, yyDollar[2].stmt)
 		}
 	case 58:
-		yyDollar = yyS[yypt-3 : yypt+1]
-//line hawk.y:337
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line hawk.y:359
 		{
-			yyVAL.expr = &BinaryExpr{genDebugInfo(), LtEq, yyDollar[1].expr, yyDollar[3].expr}
+			yyVAL.stmt = popLabel(yylex, yyDollar[2].stmt)
 		}
 	case 59:
-		yyDollar = yyS[yypt-3 : yypt+1]
-//line hawk.y:341
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line hawk.y:363
 		{
-			yyVAL.expr = &BinaryExpr{genDebugInfo(), GtEq, yyDollar[1].expr, yyDollar[3].expr}
+			yyVAL.stmt = popLabel(yylex, yyDollar[2].stmt)
 		}
 	case 60:
-		yyDollar = yyS[yypt-3 : yypt+1]
-//line hawk.y:345
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line hawk.y:369
 		{
-			yyVAL.expr = &BinaryExpr{genDebugInfo(), Lt, yyDollar[1].expr, yyDollar[3].expr}
+			pushLabel(yylex, yyDollar[1].sym)
 		}
 	case 61:
-		yyDollar = yyS[yypt-3 : yypt+1]
-//line hawk.y:349
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line hawk.y:376
 		{
-			yyVAL.expr = &BinaryExpr{genDebugInfo(), Gt, yyDollar[1].expr, yyDollar[3].expr}
+			yyVAL.expr = yyDollar[1].expr
 		}
 	case 62:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line hawk.y:353
+//line hawk.y:380
 		{
-			yyVAL.expr = &BinaryExpr{genDebugInfo(), Add, yyDollar[1].expr, yyDollar[3].expr}
+			yyVAL.expr = &AssignExpr{genDebugInfo(yylex), nil, 0, checkAssign(yylex, yyDollar[1].expr), yyDollar[3].expr, false}
 		}
 	case 63:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line hawk.y:357
+//line hawk.y:384
 		{
-			yyVAL.expr = &BinaryExpr{genDebugInfo(), Sub, yyDollar[1].expr, yyDollar[3].expr}
+			yyVAL.expr = &AssignExpr{genDebugInfo(yylex), nil, Add, checkAssign(yylex, yyDollar[1].expr), yyDollar[3].expr, false}
 		}
 	case 64:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line hawk.y:361
+//line hawk.y:388
 		{
-			yyVAL.expr = &BinaryExpr{genDebugInfo(), Mul, yyDollar[1].expr, yyDollar[3].expr}
+			yyVAL.expr = &AssignExpr{genDebugInfo(yylex), nil, Sub, checkAssign(yylex, yyDollar[1].expr), yyDollar[3].expr, false}
 		}
 	case 65:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line hawk.y:365
+//line hawk.y:392
 		{
-			yyVAL.expr = &BinaryExpr{genDebugInfo(), Div, yyDollar[1].expr, yyDollar[3].expr}
+			yyVAL.expr = &AssignExpr{genDebugInfo(yylex), nil, Mul, checkAssign(yylex, yyDollar[1].expr), yyDollar[3].expr, false}
 		}
 	case 66:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line hawk.y:369
+//line hawk.y:396
 		{
-			yyVAL.expr = &BinaryExpr{genDebugInfo(), Mod, yyDollar[1].expr, yyDollar[3].expr}
+			yyVAL.expr = &AssignExpr{genDebugInfo(yylex), nil, Div, checkAssign(yylex, yyDollar[1].expr), yyDollar[3].expr, false}
 		}
 	case 67:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line hawk.y:373
+//line hawk.y:400
 		{
-			yyVAL.expr = &BinaryExpr{genDebugInfo(), Concat, yyDollar[1].expr, yyDollar[3].expr}
+			yyVAL.expr = &AssignExpr{genDebugInfo(yylex), nil, Mod, checkAssign(yylex, yyDollar[1].expr), yyDollar[3].expr, false}
 		}
 	case 68:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line hawk.y:377
+//line hawk.y:404
 		{
-			yyVAL.expr = &MatchExpr{genDebugInfo(), yyDollar[1].expr, yyDollar[3].expr, true}
+			yyVAL.expr = &AssignExpr{genDebugInfo(yylex), nil, Concat, checkAssign(yylex, yyDollar[1].expr), yyDollar[3].expr, false}
 		}
 	case 69:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line hawk.y:381
+//line hawk.y:408
 		{
-			yyVAL.expr = &MatchExpr{genDebugInfo(), yyDollar[1].expr, yyDollar[3].expr, false}
+			yyVAL.expr = &AssignExpr{genDebugInfo(yylex), nil, Pow, checkAssign(yylex, yyDollar[1].expr), yyDollar[3].expr, false}
 		}
 	case 70:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line hawk.y:412
+		{
+			yyVAL.expr = &AssignExpr{genDebugInfo(yylex), nil, And, checkAssign(yylex, yyDollar[1].expr), yyDollar[3].expr, false}
+		}
+	case 71:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line hawk.y:416
+		{
+			yyVAL.expr = &AssignExpr{genDebugInfo(yylex), nil, Or, checkAssign(yylex, yyDollar[1].expr), yyDollar[3].expr, false}
+		}
+	case 72:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line hawk.y:420
+		{
+			yyVAL.expr = &AssignExpr{genDebugInfo(yylex), nil, Xor, checkAssign(yylex, yyDollar[1].expr), yyDollar[3].expr, false}
+		}
+	case 73:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line hawk.y:424
+		{
+			yyVAL.expr = &AssignExpr{genDebugInfo(yylex), nil, AndNot, checkAssign(yylex, yyDollar[1].expr), yyDollar[3].expr, false}
+		}
+	case 74:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line hawk.y:428
+		{
+			yyVAL.expr = &AssignExpr{genDebugInfo(yylex), nil, Shl, checkAssign(yylex, yyDollar[1].expr), yyDollar[3].expr, false}
+		}
+	case 75:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line hawk.y:432
+		{
+			yyVAL.expr = &AssignExpr{genDebugInfo(yylex), nil, Shr, checkAssign(yylex, yyDollar[1].expr), yyDollar[3].expr, false}
+		}
+	case 76:
+		yyDollar = yyS[yypt-5 : yypt+1]
+//line hawk.y:436
+		{
+			yyVAL.expr = &TernaryExpr{genDebugInfo(yylex), yyDollar[1].expr, yyDollar[3].expr, yyDollar[5].expr}
+		}
+	case 77:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line hawk.y:440
+		{
+			yyVAL.expr = &BinaryExpr{genDebugInfo(yylex), nil, OrOr, yyDollar[1].expr, yyDollar[3].expr}
+		}
+	case 78:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line hawk.y:444
+		{
+			yyVAL.expr = &BinaryExpr{genDebugInfo(yylex), nil, AndAnd, yyDollar[1].expr, yyDollar[3].expr}
+		}
+	case 79:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line hawk.y:448
+		{
+			yyVAL.expr = &BinaryExpr{genDebugInfo(yylex), nil, Eq, yyDollar[1].expr, yyDollar[3].expr}
+		}
+	case 80:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line hawk.y:452
+		{
+			yyVAL.expr = &BinaryExpr{genDebugInfo(yylex), nil, NotEq, yyDollar[1].expr, yyDollar[3].expr}
+		}
+	case 81:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line hawk.y:456
+		{
+			yyVAL.expr = &BinaryExpr{genDebugInfo(yylex), nil, LtEq, yyDollar[1].expr, yyDollar[3].expr}
+		}
+	case 82:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line hawk.y:460
+		{
+			yyVAL.expr = &BinaryExpr{genDebugInfo(yylex), nil, GtEq, yyDollar[1].expr, yyDollar[3].expr}
+		}
+	case 83:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line hawk.y:464
+		{
+			yyVAL.expr = &BinaryExpr{genDebugInfo(yylex), nil, Lt, yyDollar[1].expr, yyDollar[3].expr}
+		}
+	case 84:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line hawk.y:468
+		{
+			yyVAL.expr = &BinaryExpr{genDebugInfo(yylex), nil, Gt, yyDollar[1].expr, yyDollar[3].expr}
+		}
+	case 85:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line hawk.y:472
+		{
+			yyVAL.expr = &BinaryExpr{genDebugInfo(yylex), nil, Add, yyDollar[1].expr, yyDollar[3].expr}
+		}
+	case 86:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line hawk.y:476
+		{
+			yyVAL.expr = &BinaryExpr{genDebugInfo(yylex), nil, Sub, yyDollar[1].expr, yyDollar[3].expr}
+		}
+	case 87:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line hawk.y:480
+		{
+			yyVAL.expr = &BinaryExpr{genDebugInfo(yylex), nil, Mul, yyDollar[1].expr, yyDollar[3].expr}
+		}
+	case 88:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line hawk.y:484
+		{
+			yyVAL.expr = &BinaryExpr{genDebugInfo(yylex), nil, Div, yyDollar[1].expr, yyDollar[3].expr}
+		}
+	case 89:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line hawk.y:488
+		{
+			yyVAL.expr = &BinaryExpr{genDebugInfo(yylex), nil, Mod, yyDollar[1].expr, yyDollar[3].expr}
+		}
+	case 90:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line hawk.y:492
+		{
+			yyVAL.expr = &BinaryExpr{genDebugInfo(yylex), nil, Pow, yyDollar[1].expr, yyDollar[3].expr}
+		}
+	case 91:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line hawk.y:496
+		{
+			yyVAL.expr = &BinaryExpr{genDebugInfo(yylex), nil, And, yyDollar[1].expr, yyDollar[3].expr}
+		}
+	case 92:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line hawk.y:500
+		{
+			yyVAL.expr = &BinaryExpr{genDebugInfo(yylex), nil, Or, yyDollar[1].expr, yyDollar[3].expr}
+		}
+	case 93:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line hawk.y:504
+		{
+			yyVAL.expr = &BinaryExpr{genDebugInfo(yylex), nil, Xor, yyDollar[1].expr, yyDollar[3].expr}
+		}
+	case 94:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line hawk.y:508
+		{
+			yyVAL.expr = &BinaryExpr{genDebugInfo(yylex), nil, AndNot, yyDollar[1].expr, yyDollar[3].expr}
+		}
+	case 95:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line hawk.y:512
+		{
+			yyVAL.expr = &BinaryExpr{genDebugInfo(yylex), nil, Shl, yyDollar[1].expr, yyDollar[3].expr}
+		}
+	case 96:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line hawk.y:516
+		{
+			yyVAL.expr = &BinaryExpr{genDebugInfo(yylex), nil, Shr, yyDollar[1].expr, yyDollar[3].expr}
+		}
+	case 97:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line hawk.y:520
+		{
+			yyVAL.expr = yyDollar[2].expr
+		}
+	case 98:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line hawk.y:524
+		{
+			yyVAL.expr = &UnaryExpr{genDebugInfo(yylex), nil, Minus, yyDollar[2].expr}
+		}
+	case 99:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line hawk.y:528
+		{
+			yyVAL.expr = &UnaryExpr{genDebugInfo(yylex), nil, Not, yyDollar[2].expr}
+		}
+	case 100:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line hawk.y:532
+		{
+			yyVAL.expr = &UnaryExpr{genDebugInfo(yylex), nil, Compl, yyDollar[2].expr}
+		}
+	case 101:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line hawk.y:536
+		{
+			yyVAL.expr = &BinaryExpr{genDebugInfo(yylex), nil, Concat, yyDollar[1].expr, yyDollar[3].expr}
+		}
+	case 102:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line hawk.y:540
+		{
+			yyVAL.expr = &MatchExpr{genDebugInfo(yylex), yyDollar[1].expr, yyDollar[3].expr, true}
+		}
+	case 103:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line hawk.y:544
+		{
+			yyVAL.expr = &MatchExpr{genDebugInfo(yylex), yyDollar[1].expr, yyDollar[3].expr, false}
+		}
+	case 104:
 		yyDollar = yyS[yypt-0 : yypt+1]
-//line hawk.y:386
+//line hawk.y:549
 		{
 			yyVAL.expr = nil
 		}
-	case 71:
+	case 105:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line hawk.y:390
+//line hawk.y:553
 		{
 			yyVAL.expr = yyDollar[1].expr
 		}
-	case 72:
+	case 106:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line hawk.y:397
+//line hawk.y:560
 		{
 			yyVAL.expr = BasicLit{yyDollar[1].val}
 		}
-	case 73:
+	case 107:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line hawk.y:401
+//line hawk.y:564
 		{
 			yyVAL.expr = BasicLit{value.NewString(yyDollar[1].sym)}
 		}
-	case 74:
+	case 108:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line hawk.y:405
+//line hawk.y:568
 		{
 			yyVAL.expr = BasicLit{value.NewBool(yyDollar[1].sym == "true")}
 		}
-	case 75:
-		yyDollar = yyS[yypt-2 : yypt+1]
-//line hawk.y:409
+	case 109:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line hawk.y:572
 		{
 			yyVAL.expr = yyDollar[2].expr
 		}
-	case 76:
+	case 110:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line hawk.y:413
+//line hawk.y:576
 		{
-			yyVAL.expr = &UnaryExpr{genDebugInfo(), Minus, yyDollar[2].expr}
+			yyVAL.expr = &AssignExpr{genDebugInfo(yylex), nil, Add, checkAssign(yylex, yyDollar[1].expr), BasicLit{value.NewInt(1)}, true}
 		}
-	case 77:
+	case 111:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line hawk.y:417
+//line hawk.y:580
 		{
-			yyVAL.expr = &UnaryExpr{genDebugInfo(), Not, yyDollar[2].expr}
+			yyVAL.expr = &AssignExpr{genDebugInfo(yylex), nil, Sub, checkAssign(yylex, yyDollar[1].expr), BasicLit{value.NewInt(1)}, true}
 		}
-	case 78:
-		yyDollar = yyS[yypt-3 : yypt+1]
-//line hawk.y:421
+	case 112:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line hawk.y:584
 		{
-			yyVAL.expr = yyDollar[2].expr
+			yyVAL.expr = &AssignExpr{genDebugInfo(yylex), nil, Add, checkAssign(yylex, yyDollar[2].expr), BasicLit{value.NewInt(1)}, false}
 		}
-	case 79:
+	case 113:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line hawk.y:588
+		{
+			yyVAL.expr = &AssignExpr{genDebugInfo(yylex), nil, Sub, checkAssign(yylex, yyDollar[2].expr), BasicLit{value.NewInt(1)}, false}
+		}
+	case 114:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line hawk.y:425
+//line hawk.y:592
 		{
 			yyVAL.expr = &Ident{Name: yyDollar[1].sym}
 		}
-	case 80:
-		yyDollar = yyS[yypt-3 : yypt+1]
-//line hawk.y:429
+	case 115:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line hawk.y:596
 		{
-			yyVAL.expr = &CallExpr{genDebugInfo(), yyDollar[1].sym, nil}
+			if id, ok := yyDollar[1].expr.(*Ident); ok {
+				yyVAL.expr = &CallExpr{genDebugInfo(yylex), nil, id.Name, yyDollar[2].exprlist, nil}
+			} else {
+				yyVAL.expr = &CallValueExpr{genDebugInfo(yylex), nil, yyDollar[1].expr, yyDollar[2].exprlist}
+			}
 		}
-	case 81:
-		yyDollar = yyS[yypt-5 : yypt+1]
-//line hawk.y:433
+	case 116:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line hawk.y:604
 		{
-			yyVAL.expr = &CallExpr{genDebugInfo(), yyDollar[1].sym, yyDollar[3].exprlist}
+			popLabel(yylex, nil)
+			fn := &FuncDecl{genDebugInfo(yylex), &FuncScope{}, "", yyDollar[1].symlist, yyDollar[2].blockstmt, nil}
+			prog := yylex.(*yyLex).prog
+			prog.lits = append(prog.lits, fn)
+			yyVAL.expr = &FuncLit{Decl: fn}
 		}
-	case 82:
+	case 117:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line hawk.y:437
+//line hawk.y:612
 		{
 			yyVAL.expr = &ArrayLit{}
 		}
-	case 83:
+	case 118:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line hawk.y:441
+//line hawk.y:616
 		{
 			yyVAL.expr = &ArrayLit{yyDollar[2].exprlist}
 		}
-	case 84:
-		yyDollar = yyS[yypt-1 : yypt+1]
-//line hawk.y:445
+	case 119:
+		yyDollar = yyS[yypt-4 : yypt+1]
+//line hawk.y:620
 		{
-			yyVAL.expr = yyDollar[1].expr
+			yyVAL.expr = &IndexExpr{genDebugInfo(yylex), nil, yyDollar[1].expr, yyDollar[3].expr}
 		}
-	case 85:
+	case 120:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line hawk.y:624
+		{
+			// Only valid on the left of '=', to append; see the analyser.
+			yyVAL.expr = &IndexExpr{genDebugInfo(yylex), nil, yyDollar[1].expr, nil}
+		}
+	case 121:
+		yyDollar = yyS[yypt-6 : yypt+1]
+//line hawk.y:629
+		{
+			yyVAL.expr = &SliceExpr{genDebugInfo(yylex), yyDollar[1].expr, yyDollar[3].expr, yyDollar[5].expr}
+		}
+	case 122:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line hawk.y:633
+		{
+			yyVAL.expr = &FieldExpr{genDebugInfo(yylex), nil, yyDollar[2].expr}
+		}
+	case 123:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line hawk.y:637
+		{
+			yyVAL.expr = &FieldExpr{genDebugInfo(yylex), nil, &UnaryExpr{genDebugInfo(yylex), nil, Minus, yyDollar[3].expr}}
+		}
+	case 124:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line hawk.y:452
+//line hawk.y:644
 		{
-			yyVAL.expr = &IndexExpr{genDebugInfo(), &Ident{Name: yyDollar[1].sym}, yyDollar[3].expr}
+			// Labels are not visible in function literals.
+			pushLabel(yylex, "")
+			yyVAL.symlist = yyDollar[3].symlist
 		}
-	case 86:
+	case 125:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line hawk.y:652
+		{
+			yyVAL.exprlist = nil
+		}
+	case 126:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line hawk.y:456
+//line hawk.y:656
 		{
-			yyVAL.expr = &IndexExpr{genDebugInfo(), yyDollar[1].expr, yyDollar[3].expr}
+			yyVAL.exprlist = yyDollar[2].exprlist
 		}
-	case 87:
+	case 127:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line hawk.y:463
+//line hawk.y:663
 		{
 			yyVAL.exprlist = []Expr{yyDollar[1].expr}
 		}
-	case 88:
+	case 128:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line hawk.y:467
+//line hawk.y:667
 		{
 			yyVAL.exprlist = append(yyDollar[1].exprlist, yyDollar[3].expr)
 		}
@@ -10,13 +10,6 @@ import (
 	"github.com/mibk/hawk/scan"
 	"github.com/mibk/hawk/value"
 )
-
-var (
-	progName string
-	ast    *Program
-
-	defaultAction = &BlockStmt{[]Stmt{&PrintStmt{Fun: "print"}}}
-)
 %}
 
 %union {
@@ -30,45 +23,64 @@ var (
 	stmt      Stmt
 	stmtlist  []Stmt
 	blockstmt *BlockStmt
+	caseclause *CaseClause
+	caselist  []*CaseClause
 }
 
-%type <decl>      decl action funcdecl
-%type <symlist>   arglist
+%type <decl>      decl paction funcdecl
+%type <symlist>   arglist funclit
 %type <decllist>  decllist
-%type <expr>      expr oexpr uexpr indexexpr addressable
-%type <exprlist>  exprlist
-%type <stmt>      pipeline stmt ostmt ifstmt else if_or_block forstmt foreachstmt
+%type <expr>      expr oexpr uexpr
+%type <exprlist>  exprlist callargs
+%type <stmt>      pipeline stmt ostmt ifstmt else if_or_block switchstmt forstmt foreachstmt dostmt labeledstmt
 %type <blockstmt> blockstmt
+%type <caseclause> caseclause casehead
+%type <caselist>  caselist
 %type <stmtlist>  stmtlist
 
 %token <sym>  IDENT BOOL STRING PRINT
 %token <val>  NUM
+%token        BEGIN END
 %token        IF ELSE
-%token        FOR IN BREAK CONTINUE
+%token        SWITCH CASE DEFAULT FALLTHROUGH
+%token        FOR IN BREAK CONTINUE DO WHILE
 %token        INC DEC
 %token        ADDEQ SUBEQ MULEQ DIVEQ MODEQ CONCATEQ
+%token        POWEQ ANDEQ OREQ XOREQ ANDNOTEQ SHLEQ SHREQ
+%token        PIPE
 %token        FUNC RETURN
 
+%right '=' ADDEQ SUBEQ MULEQ DIVEQ MODEQ CONCATEQ POWEQ ANDEQ OREQ XOREQ ANDNOTEQ SHLEQ SHREQ
 %right '?' ':'
 %left OROR
 %left ANDAND
 %left EQ NE LE GE '<' '>'
 %left '~', NOTMATCH
 %left '.'
-%left '+' '-'
-%left '*' '/' '%'
+%left '+' '-' '|' '^'
+%left '*' '/' '%' SHL SHR '&' ANDNOT
+%right UNARY
+%right POW
+%left '[' INC DEC
+%right '$'
+%left '('
 
 %%
 
 top:
 	decllist ';'
 	{
+		prog := yylex.(*yyLex).prog
 		for _, d := range $1 {
 			switch d := d.(type) {
-			case *Action:
-				ast.Actions = append(ast.Actions, d)
+			case *BeginAction:
+				prog.begins = append(prog.begins, d)
+			case *PatternAction:
+				prog.pActions = append(prog.pActions, d)
+			case *EndAction:
+				prog.ends = append(prog.ends, d)
 			case *FuncDecl:
-				ast.funcs[d.Name] = d
+				prog.funcs[d.Name] = d
 			default:
 				panic(fmt.Sprintf("unexpected type: %T", d))
 			}
@@ -86,7 +98,7 @@ decllist:
 	}
 
 decl:
-	action
+	paction
 	{
 		$$ = $1
 	}
@@ -95,16 +107,34 @@ decl:
 		$$ = $1
 	}
 
-action:
-	blockstmt
+paction:
+	BEGIN blockstmt
+	{
+		$$ = &BeginAction{$2}
+	}
+|	END blockstmt
+	{
+		$$ = &EndAction{$2}
+	}
+|	expr
+	{
+		// The default action is { print }.
+		action := &BlockStmt{[]Stmt{&PrintStmt{Fun: "print"}}}
+		$$ = &PatternAction{genDebugInfo(yylex), $1, action}
+	}
+|	blockstmt
 	{
-		$$ = &Action{$1}
+		$$ = &PatternAction{genDebugInfo(yylex), nil, $1}
+	}
+|	expr blockstmt
+	{
+		$$ = &PatternAction{genDebugInfo(yylex), $1, $2}
 	}
 
 funcdecl:
 	FUNC IDENT '(' arglist ')' blockstmt
 	{
-		$$ = &FuncDecl{&FuncScope{}, $2, $4, $6}
+		$$ = &FuncDecl{genDebugInfo(yylex), &FuncScope{}, $2, $4, $6, nil}
 	}
 
 arglist:
@@ -148,9 +178,9 @@ pipeline:
 	{
 		$$ = $1
 	}
-|	pipeline '|' STRING
+|	pipeline PIPE STRING
 	{
-		$$ = &PipeStmt{genDebugInfo(), $1, $3}
+		$$ = &PipeStmt{genDebugInfo(yylex), $1, $3}
 	}
 
 stmt:
@@ -158,73 +188,47 @@ stmt:
 	{
 		$$ = &ExprStmt{$1}
 	}
-|	addressable '=' expr
-	{
-		$$ = &AssignStmt{genDebugInfo(), nil, $1, $3}
-	}
-
-	// The following 2 rules could be made into one by replacing IDENT/indexexpr with addressable,
-	// but then there are 3 shift/reduce conflicts.
-|	IDENT '[' ']' '=' expr
-	{
-		$$ = &AssignStmt{genDebugInfo(), nil, &IndexExpr{genDebugInfo(), &Ident{Name: $1}, nil}, $5}
-	}
-|	indexexpr '[' ']' '=' expr
-	{
-		$$ = &AssignStmt{genDebugInfo(), nil, &IndexExpr{genDebugInfo(), $1, nil}, $5}
-	}
-
-|	addressable ADDEQ expr
-	{
-		$$ = &AssignStmt{genDebugInfo(), nil, $1, &BinaryExpr{genDebugInfo(), Add, $1, $3}}
-	}
-|	addressable SUBEQ expr
-	{
-		$$ = &AssignStmt{genDebugInfo(), nil, $1, &BinaryExpr{genDebugInfo(), Sub, $1, $3}}
-	}
-|	addressable MULEQ expr
-	{
-		$$ = &AssignStmt{genDebugInfo(), nil, $1, &BinaryExpr{genDebugInfo(), Mul, $1, $3}}
-	}
-|	addressable DIVEQ expr
-	{
-		$$ = &AssignStmt{genDebugInfo(), nil, $1, &BinaryExpr{genDebugInfo(), Div, $1, $3}}
-	}
-|	addressable MODEQ expr
-	{
-		$$ = &AssignStmt{genDebugInfo(), nil, $1, &BinaryExpr{genDebugInfo(), Mod, $1, $3}}
-	}
-|	addressable CONCATEQ expr
+|	ifstmt
 	{
-		$$ = &AssignStmt{genDebugInfo(), nil, $1, &BinaryExpr{genDebugInfo(), Concat, $1, $3}}
+		$$ = $1
 	}
-|	addressable INC
+|	switchstmt
 	{
-		$$ = &AssignStmt{genDebugInfo(), nil, $1, &BinaryExpr{genDebugInfo(), Add, $1, BasicLit{value.NewNumber(1)}}}
+		$$ = $1
 	}
-|	addressable DEC
+|	forstmt
 	{
-		$$ = &AssignStmt{genDebugInfo(), nil, $1, &BinaryExpr{genDebugInfo(), Sub, $1, BasicLit{value.NewNumber(1)}}}
+		$$ = $1
 	}
-|	ifstmt
+|	foreachstmt
 	{
 		$$ = $1
 	}
-|	forstmt
+|	dostmt
 	{
 		$$ = $1
 	}
-|	foreachstmt
+|	labeledstmt
 	{
 		$$ = $1
 	}
 |	BREAK
 	{
-		$$ = &StatusStmt{StatusBreak}
+		$$ = &StatusStmt{genDebugInfo(yylex), nil, StatusBreak, ""}
+	}
+|	BREAK IDENT
+	{
+		$$ = &StatusStmt{genDebugInfo(yylex), nil, StatusBreak, $2}
+		findLabel(yylex, $$.(*StatusStmt))
 	}
 |	CONTINUE
 	{
-		$$ = &StatusStmt{StatusContinue}
+		$$ = &StatusStmt{genDebugInfo(yylex), nil, StatusContinue, ""}
+	}
+|	CONTINUE IDENT
+	{
+		$$ = &StatusStmt{genDebugInfo(yylex), nil, StatusContinue, $2}
+		findLabel(yylex, $$.(*StatusStmt))
 	}
 |	RETURN oexpr
 	{
@@ -232,21 +236,11 @@ stmt:
 	}
 |	PRINT exprlist
 	{
-		$$ = &PrintStmt{genDebugInfo(), nil, $1, $2}
+		$$ = &PrintStmt{genDebugInfo(yylex), nil, $1, $2}
 	}
 |	PRINT
 	{
-		$$ = &PrintStmt{genDebugInfo(), nil, $1, nil}
-	}
-
-addressable:
-	IDENT
-	{
-		$$ = &Ident{ast, $1}
-	}
-|	indexexpr
-	{
-		$$ = $1
+		$$ = &PrintStmt{genDebugInfo(yylex), nil, $1, nil}
 	}
 
 ostmt:
@@ -261,7 +255,7 @@ ostmt:
 ifstmt:
 	IF expr blockstmt else
 	{
-		$$ = &IfStmt{genDebugInfo(), $2, $3, $4}
+		$$ = &IfStmt{genDebugInfo(yylex), $2, $3, $4}
 	}
 
 else:
@@ -283,24 +277,97 @@ if_or_block:
 		$$ = $1
 	}
 
+switchstmt:
+	SWITCH oexpr '{' caselist '}'
+	{
+		$$ = &SwitchStmt{nil, "", $2, $4}
+		checkSwitch(yylex, $$.(*SwitchStmt))
+	}
+
+caselist:
+	{
+		$$ = nil
+	}
+|	caselist caseclause
+	{
+		$$ = append($1, $2)
+	}
+
+caseclause:
+	casehead stmtlist osemi
+	{
+		$1.Body = &BlockStmt{$2}
+		$$ = $1
+	}
+|	casehead stmtlist osemi FALLTHROUGH osemi
+	{
+		$1.Body = &BlockStmt{$2}
+		$1.Fallthrough = true
+		$$ = $1
+	}
+
+casehead:
+	CASE exprlist ':'
+	{
+		$$ = &CaseClause{debugInfo: genDebugInfo(yylex), Exprs: $2}
+	}
+|	CASE '~' exprlist ':'
+	{
+		$$ = &CaseClause{debugInfo: genDebugInfo(yylex), Exprs: $3, Regexp: true}
+	}
+|	DEFAULT ':'
+	{
+		$$ = &CaseClause{debugInfo: genDebugInfo(yylex)}
+	}
+
 forstmt:
 	FOR ostmt ';' oexpr ';' ostmt blockstmt
 	{
-		$$ = &ForStmt{genDebugInfo(), $2, $4, $6, $7}
+		$$ = &ForStmt{genDebugInfo(yylex), nil, "", $2, $4, $6, $7}
 	}
 |	FOR oexpr blockstmt
 	{
-		$$ = &ForStmt{genDebugInfo(), nil, $2, nil, $3}
+		$$ = &ForStmt{genDebugInfo(yylex), nil, "", nil, $2, nil, $3}
 	}
 
 foreachstmt:
 	FOR IDENT IN expr blockstmt
 	{
-		$$ = &ForeachStmt{genDebugInfo(), &Ident{Name: $2}, nil, $4, $5}
+		$$ = &ForeachStmt{genDebugInfo(yylex), nil, "", &Ident{Name: $2}, nil, $4, $5}
 	}
 |	FOR IDENT ',' IDENT IN expr blockstmt
 	{
-		$$ = &ForeachStmt{genDebugInfo(), &Ident{Name: $2}, &Ident{Name: $4}, $6, $7}
+		$$ = &ForeachStmt{genDebugInfo(yylex), nil, "", &Ident{Name: $2}, &Ident{Name: $4}, $6, $7}
+	}
+
+dostmt:
+	DO blockstmt WHILE expr
+	{
+		$$ = &DoStmt{genDebugInfo(yylex), nil, "", $2, $4}
+	}
+
+labeledstmt:
+	label forstmt
+	{
+		$$ = popLabel(yylex, $2)
+	}
+|	label foreachstmt
+	{
+		$$ = popLabel(yylex, $2)
+	}
+|	label dostmt
+	{
+		$$ = popLabel(yylex, $2)
+	}
+|	label switchstmt
+	{
+		$$ = popLabel(yylex, $2)
+	}
+
+label:
+	IDENT ':'
+	{
+		pushLabel(yylex, $1)
 	}
 
 
@@ -309,77 +376,173 @@ expr:
 	{
 		$$ = $1
 	}
-|	expr '?' expr ':' expr
+|	uexpr '=' expr
 	{
-		$$ = &TernaryExpr{genDebugInfo(), $1, $3, $5}
+		$$ = &AssignExpr{genDebugInfo(yylex), nil, 0, checkAssign(yylex, $1), $3, false}
 	}
-|	'$' uexpr
+|	uexpr ADDEQ expr
 	{
-		$$ = &FieldExpr{genDebugInfo(), nil, $2}
+		$$ = &AssignExpr{genDebugInfo(yylex), nil, Add, checkAssign(yylex, $1), $3, false}
+	}
+|	uexpr SUBEQ expr
+	{
+		$$ = &AssignExpr{genDebugInfo(yylex), nil, Sub, checkAssign(yylex, $1), $3, false}
+	}
+|	uexpr MULEQ expr
+	{
+		$$ = &AssignExpr{genDebugInfo(yylex), nil, Mul, checkAssign(yylex, $1), $3, false}
+	}
+|	uexpr DIVEQ expr
+	{
+		$$ = &AssignExpr{genDebugInfo(yylex), nil, Div, checkAssign(yylex, $1), $3, false}
+	}
+|	uexpr MODEQ expr
+	{
+		$$ = &AssignExpr{genDebugInfo(yylex), nil, Mod, checkAssign(yylex, $1), $3, false}
+	}
+|	uexpr CONCATEQ expr
+	{
+		$$ = &AssignExpr{genDebugInfo(yylex), nil, Concat, checkAssign(yylex, $1), $3, false}
+	}
+|	uexpr POWEQ expr
+	{
+		$$ = &AssignExpr{genDebugInfo(yylex), nil, Pow, checkAssign(yylex, $1), $3, false}
+	}
+|	uexpr ANDEQ expr
+	{
+		$$ = &AssignExpr{genDebugInfo(yylex), nil, And, checkAssign(yylex, $1), $3, false}
+	}
+|	uexpr OREQ expr
+	{
+		$$ = &AssignExpr{genDebugInfo(yylex), nil, Or, checkAssign(yylex, $1), $3, false}
+	}
+|	uexpr XOREQ expr
+	{
+		$$ = &AssignExpr{genDebugInfo(yylex), nil, Xor, checkAssign(yylex, $1), $3, false}
+	}
+|	uexpr ANDNOTEQ expr
+	{
+		$$ = &AssignExpr{genDebugInfo(yylex), nil, AndNot, checkAssign(yylex, $1), $3, false}
+	}
+|	uexpr SHLEQ expr
+	{
+		$$ = &AssignExpr{genDebugInfo(yylex), nil, Shl, checkAssign(yylex, $1), $3, false}
+	}
+|	uexpr SHREQ expr
+	{
+		$$ = &AssignExpr{genDebugInfo(yylex), nil, Shr, checkAssign(yylex, $1), $3, false}
+	}
+|	expr '?' expr ':' expr
+	{
+		$$ = &TernaryExpr{genDebugInfo(yylex), $1, $3, $5}
 	}
 |	expr OROR expr
 	{
-		$$ = &BinaryExpr{genDebugInfo(), OrOr, $1, $3}
+		$$ = &BinaryExpr{genDebugInfo(yylex), nil, OrOr, $1, $3}
 	}
 |	expr ANDAND expr
 	{
-		$$ = &BinaryExpr{genDebugInfo(), AndAnd, $1, $3}
+		$$ = &BinaryExpr{genDebugInfo(yylex), nil, AndAnd, $1, $3}
 	}
 |	expr EQ expr
 	{
-		$$ = &BinaryExpr{genDebugInfo(), Eq, $1, $3}
+		$$ = &BinaryExpr{genDebugInfo(yylex), nil, Eq, $1, $3}
 	}
 |	expr NE expr
 	{
-		$$ = &BinaryExpr{genDebugInfo(), NotEq, $1, $3}
+		$$ = &BinaryExpr{genDebugInfo(yylex), nil, NotEq, $1, $3}
 	}
 |	expr LE expr
 	{
-		$$ = &BinaryExpr{genDebugInfo(), LtEq, $1, $3}
+		$$ = &BinaryExpr{genDebugInfo(yylex), nil, LtEq, $1, $3}
 	}
 |	expr GE expr
 	{
-		$$ = &BinaryExpr{genDebugInfo(), GtEq, $1, $3}
+		$$ = &BinaryExpr{genDebugInfo(yylex), nil, GtEq, $1, $3}
 	}
 |	expr '<' expr
 	{
-		$$ = &BinaryExpr{genDebugInfo(), Lt, $1, $3}
+		$$ = &BinaryExpr{genDebugInfo(yylex), nil, Lt, $1, $3}
 	}
 |	expr '>' expr
 	{
-		$$ = &BinaryExpr{genDebugInfo(), Gt, $1, $3}
+		$$ = &BinaryExpr{genDebugInfo(yylex), nil, Gt, $1, $3}
 	}
 |	expr '+' expr
 	{
-		$$ = &BinaryExpr{genDebugInfo(), Add, $1, $3}
+		$$ = &BinaryExpr{genDebugInfo(yylex), nil, Add, $1, $3}
 	}
 |	expr '-' expr
 	{
-		$$ = &BinaryExpr{genDebugInfo(), Sub, $1, $3}
+		$$ = &BinaryExpr{genDebugInfo(yylex), nil, Sub, $1, $3}
 	}
 |	expr '*' expr
 	{
-		$$ = &BinaryExpr{genDebugInfo(), Mul, $1, $3}
+		$$ = &BinaryExpr{genDebugInfo(yylex), nil, Mul, $1, $3}
 	}
 |	expr '/' expr
 	{
-		$$ = &BinaryExpr{genDebugInfo(), Div, $1, $3}
+		$$ = &BinaryExpr{genDebugInfo(yylex), nil, Div, $1, $3}
 	}
 |	expr '%' expr
 	{
-		$$ = &BinaryExpr{genDebugInfo(), Mod, $1, $3}
+		$$ = &BinaryExpr{genDebugInfo(yylex), nil, Mod, $1, $3}
+	}
+|	expr POW expr
+	{
+		$$ = &BinaryExpr{genDebugInfo(yylex), nil, Pow, $1, $3}
+	}
+|	expr '&' expr
+	{
+		$$ = &BinaryExpr{genDebugInfo(yylex), nil, And, $1, $3}
+	}
+|	expr '|' expr
+	{
+		$$ = &BinaryExpr{genDebugInfo(yylex), nil, Or, $1, $3}
+	}
+|	expr '^' expr
+	{
+		$$ = &BinaryExpr{genDebugInfo(yylex), nil, Xor, $1, $3}
+	}
+|	expr ANDNOT expr
+	{
+		$$ = &BinaryExpr{genDebugInfo(yylex), nil, AndNot, $1, $3}
+	}
+|	expr SHL expr
+	{
+		$$ = &BinaryExpr{genDebugInfo(yylex), nil, Shl, $1, $3}
+	}
+|	expr SHR expr
+	{
+		$$ = &BinaryExpr{genDebugInfo(yylex), nil, Shr, $1, $3}
+	}
+|	'+' expr %prec UNARY
+	{
+		$$ = $2
+	}
+|	'-' expr %prec UNARY
+	{
+		$$ = &UnaryExpr{genDebugInfo(yylex), nil, Minus, $2}
+	}
+|	'!' expr %prec UNARY
+	{
+		$$ = &UnaryExpr{genDebugInfo(yylex), nil, Not, $2}
+	}
+|	'^' expr %prec UNARY
+	{
+		$$ = &UnaryExpr{genDebugInfo(yylex), nil, Compl, $2}
 	}
 |	expr '.' expr
 	{
-		$$ = &BinaryExpr{genDebugInfo(), Concat, $1, $3}
+		$$ = &BinaryExpr{genDebugInfo(yylex), nil, Concat, $1, $3}
 	}
 |	expr '~' expr
 	{
-		$$ = &MatchExpr{genDebugInfo(), $1, $3, true}
+		$$ = &MatchExpr{genDebugInfo(yylex), $1, $3, true}
 	}
 |	expr NOTMATCH expr
 	{
-		$$ = &MatchExpr{genDebugInfo(), $1, $3, false}
+		$$ = &MatchExpr{genDebugInfo(yylex), $1, $3, false}
 	}
 
 oexpr:
@@ -405,33 +568,45 @@ uexpr:
 	{
 		$$ = BasicLit{value.NewBool($1 == "true")}
 	}
-|	'+' uexpr
+|	'(' expr ')'
 	{
 		$$ = $2
 	}
-|	'-' uexpr
+|	uexpr INC
 	{
-		$$ = &UnaryExpr{genDebugInfo(), Minus, $2}
+		$$ = &AssignExpr{genDebugInfo(yylex), nil, Add, checkAssign(yylex, $1), BasicLit{value.NewInt(1)}, true}
 	}
-|	'!' uexpr
+|	uexpr DEC
 	{
-		$$ = &UnaryExpr{genDebugInfo(), Not, $2}
+		$$ = &AssignExpr{genDebugInfo(yylex), nil, Sub, checkAssign(yylex, $1), BasicLit{value.NewInt(1)}, true}
 	}
-|	'(' expr ')'
+|	INC uexpr %prec UNARY
 	{
-		$$ = $2
+		$$ = &AssignExpr{genDebugInfo(yylex), nil, Add, checkAssign(yylex, $2), BasicLit{value.NewInt(1)}, false}
+	}
+|	DEC uexpr %prec UNARY
+	{
+		$$ = &AssignExpr{genDebugInfo(yylex), nil, Sub, checkAssign(yylex, $2), BasicLit{value.NewInt(1)}, false}
 	}
 |	IDENT
 	{
 		$$ = &Ident{Name: $1}
 	}
-|	IDENT '(' ')'
+|	uexpr callargs
 	{
-		$$ = &CallExpr{genDebugInfo(), $1, nil}
+		if id, ok := $1.(*Ident); ok {
+			$$ = &CallExpr{genDebugInfo(yylex), nil, id.Name, $2, nil}
+		} else {
+			$$ = &CallValueExpr{genDebugInfo(yylex), nil, $1, $2}
+		}
 	}
-|	IDENT '(' exprlist ocomma ')'
+|	funclit blockstmt
 	{
-		$$ = &CallExpr{genDebugInfo(), $1, $3}
+		popLabel(yylex, nil)
+		fn := &FuncDecl{genDebugInfo(yylex), &FuncScope{}, "", $1, $2, nil}
+		prog := yylex.(*yyLex).prog
+		prog.lits = append(prog.lits, fn)
+		$$ = &FuncLit{Decl: fn}
 	}
 |	'[' ']'
 	{
@@ -441,20 +616,45 @@ uexpr:
 	{
 		$$ = &ArrayLit{$2}
 	}
-|	indexexpr
+|	uexpr '[' expr ']'
 	{
-		$$ = $1
+		$$ = &IndexExpr{genDebugInfo(yylex), nil, $1, $3}
+	}
+|	uexpr '[' ']'
+	{
+		// Only valid on the left of '=', to append; see the analyser.
+		$$ = &IndexExpr{genDebugInfo(yylex), nil, $1, nil}
+	}
+|	uexpr '[' oexpr ':' oexpr ']'
+	{
+		$$ = &SliceExpr{genDebugInfo(yylex), $1, $3, $5}
+	}
+|	'$' uexpr
+	{
+		$$ = &FieldExpr{genDebugInfo(yylex), nil, $2}
+	}
+|	'$' '-' uexpr %prec '$'
+	{
+		$$ = &FieldExpr{genDebugInfo(yylex), nil, &UnaryExpr{genDebugInfo(yylex), nil, Minus, $3}}
 	}
 
 
-indexexpr:
-	IDENT '[' expr ']'
+funclit:
+	FUNC '(' arglist ')'
+	{
+		// Labels are not visible in function literals.
+		pushLabel(yylex, "")
+		$$ = $3
+	}
+
+callargs:
+	'(' ')'
 	{
-		$$ = &IndexExpr{genDebugInfo(), &Ident{Name: $1}, $3}
+		$$ = nil
 	}
-|	indexexpr '[' expr ']'
+|	'(' exprlist ocomma ')'
 	{
-		$$ = &IndexExpr{genDebugInfo(), $1, $3}
+		$$ = $2
 	}
 
 
@@ -477,16 +677,19 @@ ocomma:
 
 %%
 
-// Compile compiles a Hawk program (name) from src. It is not safe
-// for concurrent use.
+// Compile compiles a Hawk program (name) from src.
 func Compile(name string, src io.Reader) (*Program, error) {
-	progName = name
 	sc := new(scan.Scanner)
-	ast = NewProgram(sc)
-	lexlineno = 1
-	nlsemi = false
-	l := &yyLex{reader: bufio.NewReader(src)}
+	prog := NewProgram(sc)
+	l := &yyLex{
+		reader: bufio.NewReader(src),
+		name:   name,
+		lineno: 1,
+		prog:   prog,
+	}
 	yyParse(l)
-	analyse(ast, sc)
-	return ast, l.err
+	if err := analyse(prog, sc); l.err == nil {
+		l.err = err
+	}
+	return prog, l.err
 }
@@ -5,8 +5,10 @@ package hawkc
 import (
 	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
 	"math/big"
+	"strings"
 	"unicode/utf8"
 
 	"github.com/mibk/hawk/value"
@@ -15,6 +17,11 @@ import (
 type yyLex struct {
 	err    error
 	reader *bufio.Reader
+	name   string
+	lineno int
+	nlsemi bool
+	prog   *Program // the program being compiled
+	labels []*label // of the statements being parsed
 	last   rune
 	peeked rune
 	buf    bytes.Buffer
@@ -22,11 +29,6 @@ type yyLex struct {
 
 const eof = -1
 
-var (
-	lexlineno int
-	nlsemi    bool
-)
-
 func init() {
 	yyErrorVerbose = true
 }
@@ -34,15 +36,15 @@ func init() {
 func (l *yyLex) Lex(yylval *yySymType) (tok int) {
 	defer func() {
 		switch tok {
-		case IDENT, PRINT, NUM, STRING, BOOL, BREAK, CONTINUE, INC, DEC, ')', '}', ']':
-			nlsemi = true
+		case IDENT, PRINT, NUM, STRING, BOOL, BREAK, CONTINUE, FALLTHROUGH, INC, DEC, ')', '}', ']':
+			l.nlsemi = true
 		default:
-			nlsemi = false
+			l.nlsemi = false
 		}
 	}()
 	for {
-		if nlsemi && l.peek() == '\n' {
-			nlsemi = false
+		if l.nlsemi && l.peek() == '\n' {
+			l.nlsemi = false
 			return ';'
 		}
 		r := l.next()
@@ -53,15 +55,15 @@ func (l *yyLex) Lex(yylval *yySymType) (tok int) {
 		}
 		switch r {
 		case eof:
-			if nlsemi {
+			if l.nlsemi {
 				// Treat EOF as \n.
-				nlsemi = false
+				l.nlsemi = false
 				return ';'
 			}
 			return 0
 		case '_':
 			return l.lexIdent(yylval)
-		case ';', '{', '}', ',', '(', ')', '$', '|', '[', ']', '~':
+		case ';', '{', '}', ',', '(', ')', '$', '[', ']', '~':
 		case '?', ':':
 		case '=':
 			if l.accept('=') {
@@ -76,10 +78,50 @@ func (l *yyLex) Lex(yylval *yySymType) (tok int) {
 		case '<':
 			if l.accept('=') {
 				return LE
+			} else if l.accept('<') {
+				if l.accept('=') {
+					return SHLEQ
+				}
+				return SHL
 			}
 		case '>':
 			if l.accept('=') {
 				return GE
+			} else if l.accept('>') {
+				if l.accept('=') {
+					return SHREQ
+				}
+				return SHR
+			}
+		case '&':
+			if l.accept('&') {
+				return ANDAND
+			} else if l.accept('^') {
+				if l.accept('=') {
+					return ANDNOTEQ
+				}
+				return ANDNOT
+			} else if l.accept('=') {
+				return ANDEQ
+			}
+		case '|':
+			if l.accept('|') {
+				return OROR
+			} else if l.accept('=') {
+				return OREQ
+			}
+			// A | followed by a string literal is a pipe.
+			r := l.next()
+			for r == ' ' || r == '\t' || r == '\n' || r == '\r' {
+				r = l.next()
+			}
+			l.backup()
+			if r == '"' || r == '\'' || r == '`' {
+				return PIPE
+			}
+		case '^':
+			if l.accept('=') {
+				return XOREQ
 			}
 		case '.':
 			if l.accept('=') {
@@ -98,7 +140,12 @@ func (l *yyLex) Lex(yylval *yySymType) (tok int) {
 				return SUBEQ
 			}
 		case '*':
-			if l.accept('=') {
+			if l.accept('*') {
+				if l.accept('=') {
+					return POWEQ
+				}
+				return POW
+			} else if l.accept('=') {
 				return MULEQ
 			}
 		case '/':
@@ -124,7 +171,7 @@ func (l *yyLex) Lex(yylval *yySymType) (tok int) {
 					} else if r == '*' && l.accept('/') {
 						break
 					} else if !nl && r == '\n' {
-						lexlineno--
+						l.lineno--
 						nl = true
 					}
 				}
@@ -146,11 +193,6 @@ func (l *yyLex) Lex(yylval *yySymType) (tok int) {
 		case ' ', '\t', '\n', '\r':
 			continue // ignore whitespace
 		default:
-			if r == '&' && l.accept('&') {
-				return ANDAND
-			} else if r == '|' && l.accept('|') {
-				return OROR
-			}
 			l.Errorf("unrecognized character %q", r)
 		}
 		return int(r)
@@ -211,7 +253,12 @@ done:
 		}
 		var z big.Int
 		z.SetString(l.buf.String(), base)
-		yylval.val = value.NewNumber(float64(z.Int64()))
+		if z.IsInt64() {
+			yylval.val = value.NewInt(z.Int64())
+		} else {
+			f, _ := new(big.Float).SetInt(&z).Float64()
+			yylval.val = value.NewNumber(f)
+		}
 	case _float:
 		var z big.Float
 		z.SetString(l.buf.String())
@@ -262,9 +309,17 @@ var symbols = []struct {
 	name string
 	tok  int
 }{
+	{"BEGIN", BEGIN},
+	{"END", END},
 	{"if", IF},
 	{"else", ELSE},
+	{"switch", SWITCH},
+	{"case", CASE},
+	{"default", DEFAULT},
+	{"fallthrough", FALLTHROUGH},
 	{"for", FOR},
+	{"do", DO},
+	{"while", WHILE},
 	{"in", IN},
 	{"break", BREAK},
 	{"continue", CONTINUE},
@@ -284,27 +339,12 @@ loop:
 		case '\n':
 			l.Error("newline in string literal")
 		case '\\':
-			switch l.next() {
-			case 'a': // alert or bell
-				r = '\a'
-			case 'b': // backspace
-				r = '\b'
-			case 'f': // form feed
-				r = '\f'
-			case 'n': // line feed or newline
-				r = '\n'
-			case 'r': // carriage return
-				r = '\r'
-			case 't': // horizontal tab
-				r = '\t'
-			case 'v': // vertical tab
-				r = '\v'
-			case '\\': // backslash
-				r = '\\'
-			case quote: // " or '
-				r = quote
-			default:
-				l.Errorf("unknown escape character \\%c", l.last)
+			r = l.next()
+			if r != quote { // " or ' is escaped as is
+				var ok bool
+				if r, ok = escapeChar(r); !ok {
+					l.Errorf("unknown escape character \\%c", l.last)
+				}
 			}
 		case quote:
 			break loop
@@ -315,6 +355,62 @@ loop:
 	return STRING
 }
 
+// escapeChar returns the character represented by the escape
+// sequence \c, except for the escaped quotes.
+func escapeChar(c rune) (r rune, ok bool) {
+	switch c {
+	case '0': // NUL
+		return 0, true
+	case 'a': // alert or bell
+		return '\a', true
+	case 'b': // backspace
+		return '\b', true
+	case 'f': // form feed
+		return '\f', true
+	case 'n': // line feed or newline
+		return '\n', true
+	case 'r': // carriage return
+		return '\r', true
+	case 't': // horizontal tab
+		return '\t', true
+	case 'v': // vertical tab
+		return '\v', true
+	case '\\': // backslash
+		return '\\', true
+	}
+	return c, false
+}
+
+// unescape replaces escape sequences in s the same way they are
+// replaced in string literals. Both quotes can be escaped.
+func unescape(s string) (string, error) {
+	if !strings.ContainsRune(s, '\\') {
+		return s, nil
+	}
+	var buf bytes.Buffer
+	esc := false
+	for _, r := range s {
+		switch {
+		case esc:
+			esc = false
+			if r != '"' && r != '\'' {
+				var ok bool
+				if r, ok = escapeChar(r); !ok {
+					return "", fmt.Errorf("unknown escape character \\%c", r)
+				}
+			}
+		case r == '\\':
+			esc = true
+			continue
+		}
+		buf.WriteRune(r)
+	}
+	if esc {
+		return "", errors.New("unterminated escape sequence")
+	}
+	return buf.String(), nil
+}
+
 func (l *yyLex) lexRawString(yylval *yySymType) int {
 	l.buf.Reset()
 loop:
@@ -341,7 +437,7 @@ loop:
 func (l *yyLex) next() (r rune) {
 	defer func() {
 		if r == '\n' {
-			lexlineno++
+			l.lineno++
 		}
 	}()
 	if l.peeked != 0 {
@@ -374,13 +470,13 @@ func (l *yyLex) peek() rune {
 func (l *yyLex) backup() {
 	l.peeked = l.last
 	if l.last == '\n' {
-		lexlineno--
+		l.lineno--
 	}
 }
 
 func (l *yyLex) Error(s string) {
 	if l.err == nil {
-		l.err = fmt.Errorf("%s:%d: %s", progName, lexlineno, s)
+		l.err = fmt.Errorf("%s:%d: %s", l.name, l.lineno, s)
 	}
 }
 
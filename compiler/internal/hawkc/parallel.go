@@ -0,0 +1,523 @@
+package hawkc
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/mibk/hawk/scan"
+	"github.com/mibk/hawk/value"
+)
+
+// Merge strategies that can be declared for global variables
+// in the MERGE array, e.g. MERGE["max"] = "max".
+var mergeStrategies = map[string]bool{
+	"sum":   true, // add up the changes made by the workers
+	"min":   true,
+	"max":   true,
+	"first": true, // the value set for the first input file
+	"last":  true, // the value set for the last input file (default)
+}
+
+// notMerged are the global variables that are never merged.
+var notMerged = map[string]bool{
+	"ARGC":    true,
+	"ARGV":    true,
+	"ENVIRON": true,
+	"MERGE":   true,
+}
+
+// A job is an input file processed by a worker.
+type job struct {
+	name string
+	arg  int // the index of name in ARGV
+
+	// base is the state of the global variables
+	// the worker started with.
+	base map[string]value.Value
+
+	// The state of the worker after the job.
+	vars    map[string]value.Value
+	records int
+
+	out  jobOutput
+	err  error
+	done chan struct{}
+}
+
+// A jobOutput holds the output of a job until the output of
+// the previous jobs is written, and then writes it through.
+type jobOutput struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+	w   io.Writer // the output, once the previous jobs are written
+	err error
+}
+
+func (o *jobOutput) Write(b []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.w == nil {
+		return o.buf.Write(b)
+	}
+	if o.err != nil {
+		return 0, o.err
+	}
+	n, err := o.w.Write(b)
+	o.err = err
+	return n, err
+}
+
+// stream writes the output held so far to w, and makes
+// the following output be written to w directly.
+func (o *jobOutput) stream(w io.Writer) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	_, o.err = w.Write(o.buf.Bytes())
+	o.buf = bytes.Buffer{}
+	o.w = w
+	return o.err
+}
+
+// Err returns the first error of writing the output.
+func (o *jobOutput) Err() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.err
+}
+
+// RunParallel is like Run, but it processes up to n input files
+// concurrently by n copies of the program. For each file, a copy
+// starts with the state of the global variables after the BEGIN
+// actions, and the output of the pattern actions is written in the
+// order of the input files. Before the END actions are run, the
+// global variables of the copies, except functions, are merged:
+// scalar values are taken from the last input file that changed
+// them, unless another strategy, such as "sum" for counters, is
+// declared in the MERGE array; arrays are merged element by element,
+// and the elements added to non-associative arrays are appended.
+// NR counts the records of the current input file only until the
+// END actions.
+func (p *Program) RunParallel(out io.Writer, stdin scan.Source, n int) (err error) {
+	defer catch(&err)
+	for _, a := range p.begins {
+		a.Exec(out)
+	}
+	if len(p.pActions) == 0 && len(p.ends) == 0 {
+		return nil
+	}
+	strategies, err := p.mergeStrategies()
+	if err != nil {
+		return err
+	}
+	if n < 1 {
+		n = 1
+	}
+
+	var mu sync.Mutex // serializes Open, Warn and Output
+	open := func(name string, i int) (scan.Source, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if name == "-" {
+			return stdin, nil
+		}
+		src, err := p.Open(name, i)
+		if err != nil && p.Warn != nil {
+			p.Warn(err)
+			return nil, nil
+		}
+		return src, err
+	}
+	var output func(name string) (io.Writer, error)
+	if p.Output != nil {
+		output = func(name string) (io.Writer, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			return p.Output(name)
+		}
+	}
+
+	jobs := make(chan *job)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		w := p.copy()
+		w.Output = output
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				p.run(w, j, open)
+			}
+		}()
+	}
+
+	// Write the output in the order of the input files. The output
+	// of the first unfinished file is written as it is produced.
+	ordered := make(chan *job, n)
+	stop := make(chan struct{})
+	written := make(chan error, 1)
+	var done []*job
+	go func() {
+		var err error
+		for j := range ordered {
+			if err != nil {
+				<-j.done
+				continue
+			}
+			err = j.out.stream(out)
+			<-j.done
+			if err == nil {
+				err = j.out.Err()
+			}
+			if err == nil {
+				err = j.err
+			}
+			if err != nil {
+				close(stop)
+			}
+			done = append(done, j)
+		}
+		written <- err
+	}()
+
+	err = p.startJobs(jobs, ordered, stop)
+	close(jobs)
+	close(ordered)
+	wg.Wait()
+	if werr := <-written; werr != nil {
+		return werr
+	}
+	if err != nil {
+		return err
+	}
+
+	p.merge(done, strategies)
+	for _, a := range p.ends {
+		a.Exec(out)
+	}
+	return nil
+}
+
+// startJobs sends a job for each input file named in ARGV both
+// to jobs and ordered until there are no more files or stop is
+// closed.
+func (p *Program) startJobs(jobs, ordered chan<- *job, stop <-chan struct{}) error {
+	next := p.inputNames()
+	var base map[string]value.Value
+	assigned := -1
+	for {
+		name, arg, err := next()
+		if err != nil || name == "" {
+			return err
+		}
+		if assigned != p.assigned {
+			// The workers can share the base until
+			// another assignment in ARGV is reached.
+			base = copyVars(p.vars)
+			assigned = p.assigned
+		}
+		j := &job{name: name, arg: arg, base: base, done: make(chan struct{})}
+		select {
+		case <-stop:
+			return nil
+		case ordered <- j:
+		}
+		jobs <- j
+	}
+}
+
+// run processes the input file of the job by the worker w,
+// a copy of p.
+func (p *Program) run(w *Program, j *job, open func(string, int) (scan.Source, error)) {
+	defer close(j.done)
+	defer catch(&j.err)
+	w.reset(p)
+	w.vars = copyVars(j.base)
+	p.adoptFuncs(w)
+	defer func() {
+		j.vars, j.records = w.vars, w.sc.RecordNumber()
+	}()
+	src, err := open(j.name, j.arg)
+	if err != nil || src == nil {
+		j.err = err
+		return
+	}
+	if c, ok := src.(io.Closer); ok && j.name != "-" {
+		defer c.Close()
+	}
+	w.sc.SetSource(src)
+	if j.err = w.openOutput(src.Name()); j.err != nil {
+		return
+	}
+	j.err = w.scan(&j.out)
+	if err := w.closeOutput(&j.out); j.err == nil {
+		j.err = err
+	}
+}
+
+// adoptFuncs replaces the function values in the global variables
+// of the worker w, which are functions of p, with the same functions
+// of w.
+func (p *Program) adoptFuncs(w *Program) {
+	for name, v := range w.vars {
+		w.vars[name] = p.adoptFunc(w, v)
+	}
+	if w.sortedIn != nil {
+		w.sortedIn = p.adoptFunc(w, w.sortedIn)
+	}
+}
+
+func (p *Program) adoptFunc(w *Program, v value.Value) value.Value {
+	switch v := v.(type) {
+	case *value.Func:
+		c := v.Impl.(*closure)
+		if c.fn.Name != "" {
+			return funcValue(w.funcs[c.fn.Name])
+		}
+		var fn *FuncDecl
+		for i, lit := range p.lits {
+			if lit == c.fn {
+				fn = w.lits[i]
+			}
+		}
+		return value.NewFunc(fn.Name, &closure{fn, p.adoptFrame(w, c.outer)})
+	case *value.Array:
+		for _, k := range v.Keys() {
+			k := k
+			v.Put(&k, p.adoptFunc(w, v.Get(&k)))
+		}
+	}
+	return v
+}
+
+// adoptFrame returns a copy of the local variables fr for w.
+func (p *Program) adoptFrame(w *Program, fr *frame) *frame {
+	if fr == nil {
+		return nil
+	}
+	c := &frame{copyVars(fr.vars), p.adoptFrame(w, fr.outer)}
+	for name, v := range c.vars {
+		c.vars[name] = p.adoptFunc(w, v)
+	}
+	return c
+}
+
+func copyVars(vars map[string]value.Value) map[string]value.Value {
+	c := make(map[string]value.Value, len(vars))
+	for name, v := range vars {
+		c[name] = value.Copy(v)
+	}
+	return c
+}
+
+// mergeStrategies returns the strategies declared in MERGE.
+func (p *Program) mergeStrategies() (map[string]string, error) {
+	m := make(map[string]string)
+	v := defined(p.vars["MERGE"])
+	if v == nil {
+		return m, nil
+	}
+	if !isArray(v) {
+		return nil, fmt.Errorf("MERGE is not an array")
+	}
+	a, _ := v.Array()
+	for _, k := range a.Keys() {
+		k := k
+		s := a.Get(&k).String()
+		if !mergeStrategies[s] {
+			return nil, fmt.Errorf("MERGE[%s]: unknown merge strategy %q", k.Encode(), s)
+		}
+		m[k.String()] = s
+	}
+	return m, nil
+}
+
+// merge merges the global variables of the workers of the
+// finished jobs into p.
+func (p *Program) merge(jobs []*job, strategies map[string]string) {
+	var names []string
+	seen := make(map[string]bool)
+	for _, j := range jobs {
+		p.records += j.records
+		for name := range j.vars {
+			if !seen[name] && !notMerged[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	for _, name := range names {
+		var bases, vals []value.Value
+		for _, j := range jobs {
+			if v := defined(j.vars[name]); v != nil && !isFunc(v) {
+				bases = append(bases, defined(j.base[name]))
+				vals = append(vals, v)
+			}
+		}
+		if v := mergeValues(strategies[name], defined(p.vars[name]), bases, vals); v != nil {
+			p.vars[name] = v
+		}
+	}
+}
+
+// defined returns v, or nil if v is nil or undefined.
+func defined(v value.Value) value.Value {
+	if u, ok := v.(*value.Undefined); ok {
+		if _, ok := u.Scalar(); ok {
+			return nil
+		}
+	}
+	return v
+}
+
+func isArray(v value.Value) bool {
+	if v == nil || isFunc(v) {
+		return false
+	}
+	_, ok := v.Scalar()
+	return !ok
+}
+
+func isFunc(v value.Value) bool {
+	_, ok := v.(*value.Func)
+	return ok
+}
+
+// mergeValues merges the values vals of a variable in the workers
+// into its current value cur using strategy. bases are the values
+// the workers started with. Any of cur and bases may be nil.
+func mergeValues(strategy string, cur value.Value, bases, vals []value.Value) value.Value {
+	if isArray(cur) {
+		return mergeArrays(strategy, cur, bases, vals)
+	}
+	for _, v := range vals {
+		if isArray(v) {
+			return mergeArrays(strategy, cur, bases, vals)
+		}
+	}
+
+	// Only the values changed by the workers count.
+	var changed []int
+	for i, v := range vals {
+		if bases[i] == nil || !equal(v, bases[i]) {
+			changed = append(changed, i)
+		}
+	}
+	if len(changed) == 0 {
+		return cur
+	}
+	switch strategy {
+	case "sum":
+		sum := number(cur)
+		for _, i := range changed {
+			sum.Add(sum, number(vals[i]))
+			sum.Sub(sum, number(bases[i]))
+		}
+		return sum
+	case "min", "max":
+		z := cur
+		for _, i := range changed {
+			if z == nil {
+				z = vals[i]
+				continue
+			}
+			cmp := compare(vals[i], z)
+			if strategy == "min" && cmp < 0 || strategy == "max" && cmp > 0 {
+				z = vals[i]
+			}
+		}
+		return z
+	case "first":
+		return vals[changed[0]]
+	default:
+		return vals[changed[len(changed)-1]]
+	}
+}
+
+// mergeArrays is mergeValues for arrays. Elements are merged
+// using mergeValues, except that elements added to arrays that
+// are non-associative are appended in the order of the workers.
+func mergeArrays(strategy string, cur value.Value, bases, vals []value.Value) value.Value {
+	type entry struct {
+		bases, vals []value.Value
+	}
+	var curArr *value.Array
+	assoc := false
+	if cur != nil {
+		curArr, _ = cur.Array()
+		assoc = isAssociative(curArr)
+	}
+	for _, v := range vals {
+		if a, ok := v.Array(); ok && isAssociative(a) {
+			assoc = true
+		}
+	}
+
+	var keys []value.Scalar
+	entries := make(map[value.Scalar]*entry)
+	if curArr != nil {
+		for _, k := range curArr.Keys() {
+			keys = append(keys, k)
+			entries[k] = new(entry)
+		}
+	}
+	var appended []value.Value
+	for i, v := range vals {
+		a, ok := v.Array()
+		if !ok {
+			continue
+		}
+		var base *value.Array
+		if isArray(bases[i]) {
+			base, _ = bases[i].Array()
+		}
+		for _, k := range a.Keys() {
+			k := k
+			var b value.Value
+			if base != nil {
+				b = defined(base.Get(&k))
+			}
+			if b == nil && !assoc {
+				appended = append(appended, a.Get(&k))
+				continue
+			}
+			e := entries[k]
+			if e == nil {
+				e = new(entry)
+				entries[k] = e
+				keys = append(keys, k)
+			}
+			e.bases = append(e.bases, b)
+			e.vals = append(e.vals, a.Get(&k))
+		}
+	}
+
+	z := value.NewArray()
+	for _, k := range keys {
+		k := k
+		var c value.Value
+		if curArr != nil {
+			c = defined(curArr.Get(&k))
+		}
+		e := entries[k]
+		if v := mergeValues(strategy, c, e.bases, e.vals); v != nil {
+			z.Put(&k, v)
+		}
+	}
+	for _, v := range appended {
+		z.Put(nil, v)
+	}
+	return z
+}
+
+// isAssociative reports whether the keys of a differ from
+// the indexes 0, 1, 2, ...
+func isAssociative(a *value.Array) bool {
+	for i, k := range a.Keys() {
+		if k.Type() != value.Number || k.Float64() != float64(i) {
+			return true
+		}
+	}
+	return false
+}
@@ -2,26 +2,90 @@ package hawkc
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/mibk/hawk/scan"
 	"github.com/mibk/hawk/value"
 )
 
 type Analyser struct {
-	prog  *Program
-	scope Scope
-	sc    *scan.Scanner
+	prog *Program
+	fn   *funcContext // the function being walked, if any
+	sc   *scan.Scanner
+	err  error // the first error found
 }
 
-func analyse(prog *Program, sc *scan.Scanner) {
-	a := &Analyser{prog, prog, sc}
-	for _, p := range prog.Actions {
+// A funcContext is a function whose body is being walked.
+type funcContext struct {
+	scope  *FuncScope
+	params map[string]bool // of a function literal
+	outer  *funcContext    // enclosing function of a function literal
+}
+
+// analyse resolves the names used in prog and returns
+// the first semantic error it finds.
+func analyse(prog *Program, sc *scan.Scanner) error {
+	a := &Analyser{prog: prog, sc: sc}
+	for _, b := range prog.begins {
+		a.walkActions(b)
+	}
+	for _, p := range prog.pActions {
 		a.walkActions(p)
 	}
-	for _, fn := range prog.funcs {
-		a.scope = fn.scope
+	for _, e := range prog.ends {
+		a.walkActions(e)
+	}
+	names := make([]string, 0, len(prog.funcs))
+	for name := range prog.funcs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fn := prog.funcs[name]
+		if _, ok := builtins[name]; ok {
+			a.errorf(fn.debugInfo, "cannot redeclare built-in function %s", name)
+		}
+		a.fn = &funcContext{scope: fn.scope}
 		a.walkStmt(fn.Body)
 	}
+	return a.err
+}
+
+// errorf records an error at di unless an error has
+// already been found.
+func (a *Analyser) errorf(di debugInfo, format string, args ...interface{}) {
+	if a.err == nil {
+		a.err = fmt.Errorf("%s:%d: %s", di.srcName, di.line, fmt.Sprintf(format, args...))
+	}
+}
+
+// lookup returns the scope of the variable name. All the variables
+// of named functions are local. The parameters of function literals
+// are local, and the other variables are those of the enclosing
+// function. Outside of functions, variables are global.
+func (a *Analyser) lookup(name string) Scope {
+	depth := 0
+	for c := a.fn; c != nil; c = c.outer {
+		if c.params == nil || c.params[name] {
+			if depth == 0 {
+				return c.scope
+			}
+			return &outerScope{a.fn.scope, depth}
+		}
+		depth++
+	}
+	return a.prog
+}
+
+// isParam reports whether name is a parameter of a function
+// literal being walked.
+func (a *Analyser) isParam(name string) bool {
+	for c := a.fn; c != nil; c = c.outer {
+		if c.params[name] {
+			return true
+		}
+	}
+	return false
 }
 
 func (a *Analyser) walkActions(pa Stmt) {
@@ -29,8 +93,13 @@ func (a *Analyser) walkActions(pa Stmt) {
 		return
 	}
 	switch pa := pa.(type) {
-	case *Action:
-		a.walkStmt(pa.Stmt)
+	case *BeginAction:
+		a.walkStmt(pa.BlockStmt)
+	case *PatternAction:
+		a.walkExpr(pa.X)
+		a.walkStmt(pa.Body)
+	case *EndAction:
+		a.walkStmt(pa.BlockStmt)
 	default:
 		panic(fmt.Sprintf("unknown pattern-action: %T", pa))
 	}
@@ -49,27 +118,39 @@ func (a *Analyser) walkStmt(s Stmt) {
 		}
 	case *PipeStmt:
 		a.walkStmt(s.Stmt)
-	case *AssignStmt:
-		s.scope = a.scope
-		a.walkExpr(s.Left)
-		a.walkExpr(s.Right)
 	case *IfStmt:
 		a.walkExpr(s.X)
 		a.walkStmt(s.Body)
 		a.walkStmt(s.Else)
+	case *SwitchStmt:
+		s.root = a.prog
+		a.walkExpr(s.Tag)
+		for _, c := range s.Cases {
+			for _, e := range c.Exprs {
+				a.walkExpr(e)
+			}
+			a.walkStmt(c.Body)
+		}
 	case *ForStmt:
+		s.root = a.prog
 		a.walkStmt(s.Init)
 		a.walkExpr(s.Cond)
 		a.walkStmt(s.Post)
 		a.walkStmt(s.Body)
 	case *ForeachStmt:
+		s.root = a.prog
 		a.walkExpr(s.Key)
 		if s.Val != nil {
 			a.walkExpr(s.Val)
 		}
 		a.walkExpr(s.X)
 		a.walkStmt(s.Body)
+	case *DoStmt:
+		s.root = a.prog
+		a.walkStmt(s.Body)
+		a.walkExpr(s.Cond)
 	case *StatusStmt:
+		s.root = a.prog
 	case *ReturnStmt:
 		s.root = a.prog
 		a.walkExpr(s.X)
@@ -94,22 +175,65 @@ func (a *Analyser) walkExpr(e Expr) {
 		a.walkExpr(e.Yes)
 		a.walkExpr(e.No)
 	case *CallExpr:
+		e.root = a.prog
+		e.scope = a.lookup(e.Fun)
 		for _, e := range e.Args {
 			a.walkExpr(e)
 		}
+	case *CallValueExpr:
+		e.root = a.prog
+		a.walkExpr(e.X)
+		for _, e := range e.Args {
+			a.walkExpr(e)
+		}
+	case *FuncLit:
+		if a.fn != nil {
+			e.outer = a.fn.scope
+		}
+		params := make(map[string]bool)
+		for _, name := range e.Decl.Args {
+			params[name] = true
+		}
+		outer := a.fn
+		a.fn = &funcContext{e.Decl.scope, params, outer}
+		a.walkStmt(e.Decl.Body)
+		a.fn = outer
 	case *Ident:
-		e.scope = a.scope
+		e.scope = a.lookup(e.Name)
+		if fn, ok := a.prog.funcs[e.Name]; ok && !a.isParam(e.Name) {
+			e.fn = fn
+		}
 	case *FieldExpr:
 		e.sc = a.sc
 		a.walkExpr(e.X)
 	case *IndexExpr:
+		if e.Index == nil {
+			a.errorf(e.debugInfo, "x[] can only be used on the left of = to append")
+		}
+		e.root = a.prog
 		a.walkExpr(e.Index)
 		a.walkExpr(e.X)
+	case *SliceExpr:
+		a.walkExpr(e.X)
+		a.walkExpr(e.Lo)
+		a.walkExpr(e.Hi)
 	case *BinaryExpr:
+		e.root = a.prog
 		a.walkExpr(e.X)
 		a.walkExpr(e.Y)
 	case *UnaryExpr:
+		e.root = a.prog
 		a.walkExpr(e.X)
+	case *AssignExpr:
+		e.root = a.prog
+		if x, ok := e.Left.(*IndexExpr); ok && x.Index == nil && e.Op == 0 {
+			// Appending to x.
+			x.root = a.prog
+			a.walkExpr(x.X)
+		} else {
+			a.walkExpr(e.Left)
+		}
+		a.walkExpr(e.Right)
 	case *MatchExpr:
 		a.walkExpr(e.X)
 		a.walkExpr(e.Y)
@@ -121,5 +245,5 @@ func (a *Analyser) walkExpr(e Expr) {
 }
 
 func (a *Analyser) defaultPrintArgs() []Expr {
-	return []Expr{&FieldExpr{X: BasicLit{value.NewNumber(0)}}}
+	return []Expr{&FieldExpr{X: BasicLit{value.NewInt(0)}}}
 }
@@ -0,0 +1,132 @@
+package hawkc
+
+import (
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/mibk/hawk/value"
+)
+
+// sortedKeys returns the keys of a in the order set in SORTED_IN.
+func (p *Program) sortedKeys(w io.Writer, di debugInfo, a *value.Array) []value.Scalar {
+	return p.orderedKeys(w, di, a, p.sortedIn, "SORTED_IN")
+}
+
+// orderedKeys returns the keys of a in the given order, which is
+// "@unsorted" (the order of insertion), "@ind_str_asc", "@ind_num_asc",
+// "@val_str_asc" or "@val_num_asc", or the same with "_desc" instead
+// of "_asc", or a function, or the name of one, that is called with two
+// elements as (k1, v1, k2, v2) and returns a negative number, zero or
+// a positive number if the first one should be ordered before, as or
+// after the second one, respectively. A nil or empty order, as set by
+// SORTED_IN = "", means "@unsorted".
+// name is used in error messages.
+func (p *Program) orderedKeys(w io.Writer, di debugInfo, a *value.Array, order value.Value, name string) []value.Scalar {
+	if order == nil || order.String() == "" || order.String() == "@unsorted" {
+		return a.Keys()
+	}
+	keys := append([]value.Scalar(nil), a.Keys()...)
+	var cmp func(k, k2 *value.Scalar) int
+	if f, ok := p.lookupFunc(order); ok {
+		if n := len(f.Impl.(*closure).fn.Args); n != 4 {
+			di.throw("%s: %v must take 4 arguments, not %d", name, f, n)
+		}
+		cmp = func(k, k2 *value.Scalar) int {
+			v, ok := p.call(w, f, []value.Value{k, a.Get(k), k2, a.Get(k2)}).Scalar()
+			if !ok {
+				di.throw("%s: %v returned a non-scalar value", name, f)
+			}
+			return sign(v)
+		}
+	} else {
+		cmp = sortOrder(order.String(), a)
+		if cmp == nil {
+			di.throw("%s: unknown function or order %q", name, order)
+		}
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		return cmp(&keys[i], &keys[j]) < 0
+	})
+	return keys
+}
+
+// sortOrder returns the comparison function of the predefined
+// order, or nil if there is no such order.
+func sortOrder(order string, a *value.Array) func(k, k2 *value.Scalar) int {
+	s := strings.TrimPrefix(order, "@")
+	desc := strings.HasSuffix(s, "_desc")
+	if !desc && !strings.HasSuffix(s, "_asc") {
+		return nil
+	}
+	s = s[:strings.LastIndex(s, "_")]
+
+	var cmp func(k, k2 *value.Scalar) int
+	switch s {
+	case "ind_str":
+		cmp = func(k, k2 *value.Scalar) int {
+			return strings.Compare(k.String(), k2.String())
+		}
+	case "ind_num":
+		cmp = func(k, k2 *value.Scalar) int {
+			if c := compareNumbers(k, k2); c != 0 {
+				return c
+			}
+			return strings.Compare(k.String(), k2.String())
+		}
+	case "val_str", "val_num":
+		cmpVals := compareStrings
+		if s == "val_num" {
+			cmpVals = compareNumbers
+		}
+		cmp = func(k, k2 *value.Scalar) int {
+			if c := cmpVals(a.Get(k), a.Get(k2)); c != 0 {
+				return c
+			}
+			return strings.Compare(k.String(), k2.String())
+		}
+	default:
+		return nil
+	}
+	if desc {
+		return func(k, k2 *value.Scalar) int { return cmp(k2, k) }
+	}
+	return cmp
+}
+
+// compareStrings compares v and w as strings. Scalar
+// values are ordered before arrays.
+func compareStrings(v, w value.Value) int {
+	if c := compareKinds(v, w); c != 0 {
+		return c
+	}
+	return strings.Compare(v.String(), w.String())
+}
+
+// compareNumbers compares v and w as numbers. Scalar
+// values are ordered before arrays.
+func compareNumbers(v, w value.Value) int {
+	if c := compareKinds(v, w); c != 0 || isArray(v) {
+		return c
+	}
+	return compare(number(v), number(w))
+}
+
+// compareValues compares v and w as the comparison operators
+// do. Scalar values are ordered before arrays.
+func compareValues(v, w value.Value) int {
+	if c := compareKinds(v, w); c != 0 || isArray(v) {
+		return c
+	}
+	return compare(v, w)
+}
+
+func compareKinds(v, w value.Value) int {
+	switch a, a2 := isArray(v), isArray(w); {
+	case a == a2:
+		return 0
+	case a:
+		return 1
+	}
+	return -1
+}
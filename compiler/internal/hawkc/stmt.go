@@ -3,6 +3,8 @@ package hawkc
 import (
 	"fmt"
 	"io"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/mibk/hawk/value"
 	"github.com/mibk/shellexec"
@@ -36,11 +38,8 @@ type BlockStmt struct {
 
 func (b *BlockStmt) Exec(w io.Writer) Status {
 	for _, stmt := range b.List {
-		switch s := stmt.Exec(w); s {
-		case StatusBreak, StatusReturn:
+		if s := stmt.Exec(w); s != StatusNone {
 			return s
-		case StatusContinue:
-			return StatusNone
 		}
 	}
 	return StatusNone
@@ -75,37 +74,6 @@ func (p *PipeStmt) Exec(w io.Writer) Status {
 	return st
 }
 
-type AssignStmt struct {
-	debugInfo
-	scope Scope
-	Left  Expr
-	Right Expr
-}
-
-func (as *AssignStmt) Exec(w io.Writer) Status {
-	v := as.Right.Eval(w)
-	switch e := as.Left.(type) {
-	case *Ident:
-		as.scope.Put(e.Name, v)
-	case *IndexExpr:
-		a, ok := e.X.Eval(w).Array()
-		if !ok {
-			as.throw("assigning to a scalar value using index expression")
-		}
-		var index *value.Scalar
-		if e.Index != nil {
-			index, ok = e.Index.Eval(w).Scalar()
-			if !ok {
-				as.throw("indexing an array using a non-scalar value")
-			}
-		}
-		a.Put(index, v)
-	default:
-		panic(fmt.Sprintf("unknown assignment type: %T", e))
-	}
-	return StatusNone
-}
-
 type IfStmt struct {
 	debugInfo
 	X    Expr
@@ -126,12 +94,87 @@ func (is *IfStmt) Exec(w io.Writer) Status {
 	return StatusNone
 }
 
+type SwitchStmt struct {
+	root  *Program
+	Label string
+	Tag   Expr // nil in a switch without an expression
+	Cases []*CaseClause
+}
+
+type CaseClause struct {
+	debugInfo
+	Exprs       []Expr // nil in the default clause
+	Regexp      bool   // case ~ exprs
+	Body        *BlockStmt
+	Fallthrough bool
+}
+
+func (ss *SwitchStmt) Exec(w io.Writer) Status {
+	var tag value.Value
+	if ss.Tag != nil {
+		tag = ss.Tag.Eval(w)
+	}
+	i := ss.match(w, tag)
+	if i < 0 {
+		return StatusNone
+	}
+	for ; i < len(ss.Cases); i++ {
+		c := ss.Cases[i]
+		s := c.Body.Exec(w)
+		if s == StatusContinue {
+			return s
+		}
+		if s, exit := ss.root.leave(s, ss.Label); exit {
+			return s
+		}
+		if !c.Fallthrough {
+			break
+		}
+	}
+	return StatusNone
+}
+
+// match returns the index of the first case clause matching tag,
+// or of the default clause if there is no such clause, or -1.
+func (ss *SwitchStmt) match(w io.Writer, tag value.Value) int {
+	def := -1
+	for i, c := range ss.Cases {
+		if c.Exprs == nil {
+			def = i
+			continue
+		}
+		for _, e := range c.Exprs {
+			if c.matches(w, tag, e.Eval(w)) {
+				return i
+			}
+		}
+	}
+	return def
+}
+
+func (c *CaseClause) matches(w io.Writer, tag, v value.Value) bool {
+	switch {
+	case c.Regexp:
+		return c.debugInfo.match(tag, v)
+	case tag == nil:
+		z, ok := v.Scalar()
+		if !ok {
+			c.throw("non-scalar value used as a condition")
+		}
+		return z.Bool()
+	}
+	cmp, _ := tag.Cmp(v)
+	return cmp == 0
+}
+
 type ForStmt struct {
 	debugInfo
-	Init Stmt
-	Cond Expr
-	Post Stmt
-	Body *BlockStmt
+	root  *Program
+	Label string
+	Init  Stmt
+	Cond  Expr
+	Post  Stmt
+	Body  *BlockStmt
 }
 
 func (f *ForStmt) Exec(w io.Writer) Status {
@@ -148,11 +191,8 @@ func (f *ForStmt) Exec(w io.Writer) Status {
 				return StatusNone
 			}
 		}
-		switch f.Body.Exec(w) {
-		case StatusBreak:
-			return StatusNone
-		case StatusReturn:
-			return StatusReturn
+		if s, exit := f.root.leave(f.Body.Exec(w), f.Label); exit {
+			return s
 		}
 		if f.Post != nil {
 			f.Post.Exec(w)
@@ -160,12 +200,37 @@ func (f *ForStmt) Exec(w io.Writer) Status {
 	}
 }
 
+type DoStmt struct {
+	debugInfo
+	root  *Program
+	Label string
+	Body  *BlockStmt
+	Cond  Expr
+}
+
+func (d *DoStmt) Exec(w io.Writer) Status {
+	for {
+		if s, exit := d.root.leave(d.Body.Exec(w), d.Label); exit {
+			return s
+		}
+		v, ok := d.Cond.Eval(w).Scalar()
+		if !ok {
+			d.throw("non-scalar value used as a condition")
+		}
+		if !v.Bool() {
+			return StatusNone
+		}
+	}
+}
+
 type ForeachStmt struct {
 	debugInfo
-	Key  *Ident
-	Val  *Ident
-	X    Expr
-	Body *BlockStmt
+	root  *Program
+	Label string
+	Key   *Ident
+	Val   *Ident
+	X     Expr
+	Body  *BlockStmt
 }
 
 func (fs ForeachStmt) Exec(w io.Writer) Status {
@@ -173,28 +238,47 @@ func (fs ForeachStmt) Exec(w io.Writer) Status {
 	if !ok {
 		fs.throw("attempting to range over a scalar value")
 	}
-	for _, k := range a.Keys() {
+	for _, k := range fs.root.sortedKeys(w, fs.debugInfo, a) {
 		if fs.Key != nil {
 			fs.Key.scope.Put(fs.Key.Name, &k)
 		}
 		if fs.Val != nil {
 			fs.Val.scope.Put(fs.Val.Name, a.Get(&k))
 		}
-		switch fs.Body.Exec(w) {
-		case StatusBreak:
-			return StatusNone
-		case StatusReturn:
-			return StatusReturn
+		if s, exit := fs.root.leave(fs.Body.Exec(w), fs.Label); exit {
+			return s
 		}
 	}
 	return StatusNone
 }
 
+// leave handles the status s returned by the body of a loop, or
+// a switch, labeled label. It reports whether the statement ends,
+// and returns the status the statement returns then.
+func (p *Program) leave(s Status, label string) (Status, bool) {
+	switch s {
+	case StatusBreak, StatusContinue:
+		if p.label != "" && p.label != label {
+			return s, true // of an outer statement
+		}
+		return StatusNone, s == StatusBreak
+	case StatusReturn:
+		return s, true
+	}
+	return StatusNone, false
+}
+
+// A StatusStmt is break or continue. Label is the label
+// of the statement it refers to, if any.
 type StatusStmt struct {
+	debugInfo
+	root   *Program
 	Status Status
+	Label  string
 }
 
 func (s *StatusStmt) Exec(io.Writer) Status {
+	s.root.label = s.Label
 	return s.Status
 }
 
@@ -220,7 +304,7 @@ type PrintStmt struct {
 func (p *PrintStmt) Exec(w io.Writer) Status {
 	switch p.Fun {
 	case "print":
-		var vals []interface{}
+		var vals []value.Value
 		for _, e := range p.Args {
 			vals = append(vals, e.Eval(w))
 		}
@@ -228,11 +312,11 @@ func (p *PrintStmt) Exec(w io.Writer) Status {
 			if i != 0 {
 				fmt.Fprint(w, p.root.outputFieldSep)
 			}
-			fmt.Fprint(w, v)
+			fmt.Fprint(w, p.root.conv.Output(v))
 		}
 		fmt.Fprint(w, p.root.outputRowSep)
 	case "printf":
-		format, vals, err := formatPrintfArgs(w, "printf", p.Args)
+		format, vals, err := formatPrintfArgs(w, "printf", p.Args, p.root.bignum)
 		if err != nil {
 			p.throw("%v", err)
 		}
@@ -243,30 +327,68 @@ func (p *PrintStmt) Exec(w io.Writer) Status {
 	return StatusNone
 }
 
-func formatPrintfArgs(w io.Writer, fname string, exprs []Expr) (string, []interface{}, error) {
+func formatPrintfArgs(w io.Writer, fname string, exprs []Expr, bignum bool) (string, []interface{}, error) {
 	if len(exprs) == 0 {
 		return "", nil, fmt.Errorf("%s: not enough arguments: 0", fname)
 	}
 	var vals []interface{}
 	for _, e := range exprs[1:] {
-		vals = append(vals, e.Eval(w))
+		v := e.Eval(w)
+		if s, ok := v.(*value.Scalar); ok && bignum {
+			vals = append(vals, bigArg{s})
+			continue
+		}
+		vals = append(vals, v)
+	}
+
+	format, verbs := printfVerbs(exprs[0].Eval(w).String())
+	for i, v := range vals {
+		if f, ok := v.(*value.Func); ok && i < len(verbs) && verbs[i] != 'v' && verbs[i] != 'V' {
+			return "", nil, fmt.Errorf("%s: cannot format %v using %%%c", fname, f, verbs[i])
+		}
 	}
+	return format, vals, nil
+}
 
-	// Replace %T with %V.
-	format := []byte(exprs[0].Eval(w).String())
-	inVerb := false
-	for i := 0; i < len(format); i++ {
-		c := format[i]
-		if c == '%' {
-			inVerb = !inVerb
+// printfVerbs returns format with %T replaced by %V, and the verbs
+// of the arguments in the order they are used. A width or precision
+// given by an argument, as in %*d, is counted as the verb '*'.
+func printfVerbs(format string) (string, []rune) {
+	b := []byte(format)
+	var verbs []rune
+	for i := 0; i < len(b); i++ {
+		if b[i] != '%' {
 			continue
 		}
-		if inVerb && (c >= 'a' || c <= 'z') || (c >= 'A' && c <= 'Z') {
-			inVerb = false
-			if c == 'T' {
-				format[i] = 'V'
+		for i++; i < len(b) && strings.IndexByte("+-# 0.123456789*", b[i]) >= 0; i++ {
+			if b[i] == '*' {
+				verbs = append(verbs, '*')
 			}
 		}
+		if i == len(b) || b[i] == '%' {
+			continue
+		}
+		if b[i] == 'T' {
+			b[i] = 'V'
+		}
+		r, n := utf8.DecodeRune(b[i:])
+		verbs = append(verbs, r)
+		i += n - 1
+	}
+	return string(b), verbs
+}
+
+// bigArg is a printf argument in bignum mode. It is formatted
+// as an arbitrary-precision number if the verb is numeric.
+type bigArg struct {
+	*value.Scalar
+}
+
+func (a bigArg) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v', 'V', 's', 'q', 't':
+		a.Scalar.Format(s, verb)
+	default:
+		a.Big().Format(s, verb)
 	}
-	return string(format), vals, nil
 }
@@ -1,8 +1,12 @@
 package hawkc
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/mibk/hawk/scan"
 	"github.com/mibk/hawk/value"
@@ -19,28 +23,102 @@ type Program struct {
 	sc     *scan.Scanner
 	vars   map[string]value.Value
 	funcs  map[string]*FuncDecl
+	lits   []*FuncDecl // function literals in the order of the source
 	retval value.Value
+	label  string // of the break or continue statement being executed
 
 	// For print function.
 	outputRowSep   string
 	outputFieldSep string
 
-	Actions []Stmt
+	// conv holds CONVFMT and OFMT.
+	conv value.Conv
+
+	sortedIn value.Value // order of for-in loops, see sortedKeys
+
+	// In bignum mode, arithmetic uses arbitrary-precision
+	// numbers; prec is the precision of the arithmetic
+	// functions in bits.
+	bignum bool
+	prec   uint
+
+	records  int // processed by parallel workers
+	assigned int // number of assignments in ARGV
+
+	begins   []Stmt
+	pActions []Stmt
+	ends     []Stmt
+
+	// Open opens the input files named in ARGV. i is the index
+	// of the name in ARGV.
+	Open func(name string, i int) (scan.Source, error)
+
+	// Warn, if not nil, is called with the errors of the input
+	// files that cannot be opened; such files are skipped.
+	// Otherwise these errors stop the program.
+	Warn func(err error)
+
+	// Output, if not nil, returns the writer for the output of
+	// the pattern actions while the named input file is read.
+	// It is called when each file is opened, even if the file
+	// is empty, and the writer is closed when the file has been
+	// read if it is an io.Closer.
+	Output  func(name string) (io.Writer, error)
+	fileOut io.Writer // returned by Output for the current file
+}
+
+type BeginAction struct {
+	*BlockStmt
 }
 
-type Action struct {
-	Stmt
+type PatternAction struct {
+	debugInfo
+	X    Expr
+	Body *BlockStmt
+}
+
+func (p *PatternAction) Exec(w io.Writer) Status {
+	if p.X == nil {
+		return p.Body.Exec(w)
+	}
+	v, ok := p.X.Eval(w).Scalar()
+	if !ok {
+		p.throw("non-scalar value used as a pattern")
+	}
+	if v.Bool() {
+		return p.Body.Exec(w)
+	}
+	return StatusNone
+}
+
+type EndAction struct {
+	*BlockStmt
 }
 
 func NewProgram(sc *scan.Scanner) *Program {
-	return &Program{
+	p := &Program{
 		sc:    sc,
 		vars:  make(map[string]value.Value),
 		funcs: make(map[string]*FuncDecl),
 
 		outputRowSep:   "\n",
 		outputFieldSep: " ",
+		prec:           defaultPrec,
+	}
+	p.SetArgs(nil)
+	return p
+}
+
+// SetArgs sets ARGV and ARGC. ARGV[0] is "hawk"; args, the input
+// files and var=value assignments, follow.
+func (p *Program) SetArgs(args []string) {
+	argv := value.NewArray()
+	argv.Put(nil, value.NewString("hawk"))
+	for _, arg := range args {
+		argv.Put(nil, value.NewStrNum(arg))
 	}
+	p.vars["ARGV"] = argv
+	p.vars["ARGC"] = value.NewInt(int64(len(args) + 1))
 }
 
 func (p *Program) Get(name string) value.Value {
@@ -50,13 +128,35 @@ func (p *Program) Get(name string) value.Value {
 	// Global "magic" variables.
 	switch name {
 	case "NR":
-		return value.NewNumber(float64(p.sc.RecordNumber()))
+		return value.NewInt(int64(p.records + p.sc.RecordNumber()))
 	case "NF":
-		return value.NewNumber(float64(p.sc.FieldCount()))
+		return value.NewInt(int64(p.sc.FieldCount()))
 	case "FILENAME":
 		return value.NewString(p.sc.Filename())
 	case "FNR":
-		return value.NewNumber(float64(p.sc.FileRecordNumber()))
+		return value.NewInt(int64(p.sc.FileRecordNumber()))
+	case "RT":
+		return value.NewString(p.sc.RecordTerminator())
+	case "PREC":
+		return value.NewInt(int64(p.prec))
+	case "CONVFMT":
+		return value.NewString(format(p.conv.CONVFMT))
+	case "OFMT":
+		return value.NewString(format(p.conv.OFMT))
+	case "SORTED_IN":
+		if p.sortedIn == nil {
+			return &value.Undefined{}
+		}
+		return p.sortedIn
+	case "ENVIRON":
+		env := value.NewArray()
+		for _, kv := range os.Environ() {
+			if i := strings.Index(kv, "="); i > 0 {
+				env.Put(value.NewString(kv[:i]), value.NewStrNum(kv[i+1:]))
+			}
+		}
+		p.vars[name] = env
+		return env
 	}
 	v := &value.Undefined{}
 	p.vars[name] = v
@@ -71,49 +171,323 @@ func (p *Program) Put(name string, v value.Value) {
 		p.outputRowSep = v.String()
 	case "FS":
 		p.sc.SetFieldSep(v.String())
+	case "FIELDWIDTHS":
+		p.sc.SetFieldWidths(v.String())
+	case "FPAT":
+		p.sc.SetFieldPattern(v.String())
 	case "OFS":
 		p.outputFieldSep = v.String()
+	case "PREC":
+		if s, ok := v.Scalar(); ok && s.Int() > 0 {
+			p.prec = uint(s.Int())
+		}
+	case "CONVFMT":
+		p.conv.CONVFMT = v.String()
+	case "OFMT":
+		p.conv.OFMT = v.String()
+	case "SORTED_IN":
+		// An empty or undefined order restores the default one.
+		p.sortedIn = v
+		if _, ok := v.(*value.Undefined); ok || v.String() == "" {
+			p.sortedIn = nil
+		}
 	default:
 		p.vars[name] = v
 	}
 }
 
+// defaultPrec is the default value of PREC, the precision
+// of a float64.
+const defaultPrec = 53
+
+// format returns f, or the default format if f is empty.
+func format(f string) string {
+	if f == "" {
+		return value.DefaultFormat
+	}
+	return f
+}
+
+// SetBignum turns the bignum mode on or off. In bignum mode,
+// arithmetic operations use arbitrary-precision numbers.
+func (p *Program) SetBignum(on bool) { p.bignum = on }
+
 func (p *Program) SetFieldSep(sep string) { p.sc.SetFieldSep(sep) }
+func (p *Program) SetRowSep(sep string)   { p.sc.SetRowSep(sep) }
 
-func (p *Program) Run(out io.Writer, in scan.Source) (err error) {
-	defer func() {
-		if err == nil {
-			if v := recover(); v != nil {
-				e, ok := v.(*runtimeError)
-				if !ok {
-					panic(v)
-				}
-				err = e
+// Run runs the program. The input files named in ARGV are opened
+// using p.Open as they are reached. stdin is read if ARGV contains
+// no input files, or for the file named "-".
+func (p *Program) Run(out io.Writer, stdin scan.Source) (err error) {
+	defer catch(&err)
+	for _, a := range p.begins {
+		a.Exec(out)
+	}
+	if len(p.pActions) > 0 || len(p.ends) > 0 {
+		next, done := p.inputs(stdin, out)
+		defer done()
+		p.sc.SetSource(scan.LazyMultiSource(next))
+		if err := p.scan(out); err != nil {
+			return err
+		}
+	}
+	for _, a := range p.ends {
+		a.Exec(out)
+	}
+	return nil
+}
+
+// catch stores the runtime error the program panicked
+// with in err. It must be deferred.
+func catch(err *error) {
+	if *err == nil {
+		if v := recover(); v != nil {
+			e, ok := v.(*runtimeError)
+			if !ok {
+				panic(v)
 			}
+			*err = e
 		}
-	}()
-	p.sc.SetSource(in)
+	}
+}
+
+// scan runs the pattern actions for each record of the scanner
+// source. The output is written to out unless the output of the
+// current input file is set by openOutput.
+func (p *Program) scan(out io.Writer) error {
 	for p.sc.Scan() {
-		for _, a := range p.Actions {
-			a.Exec(out)
+		w := out
+		if p.fileOut != nil {
+			w = p.fileOut
+		}
+		for _, a := range p.pActions {
+			a.Exec(w)
 		}
 	}
 	return p.sc.Err()
 }
 
+// openOutput sets the output of the pattern actions
+// for the input file name if p.Output is set.
+func (p *Program) openOutput(name string) (err error) {
+	if p.Output != nil {
+		p.fileOut, err = p.Output(name)
+	}
+	return err
+}
+
+// closeOutput closes the output of the current input
+// file unless it is the program output out.
+func (p *Program) closeOutput(out io.Writer) error {
+	w := p.fileOut
+	p.fileOut = nil
+	if c, ok := w.(io.Closer); ok && w != out {
+		return c.Close()
+	}
+	return nil
+}
+
+// inputs returns a function that returns the input sources
+// named in ARGV one by one. Each call finishes the source
+// returned by the previous one: it closes the output of the
+// source and the source itself if it is an io.Closer, unless
+// it is stdin. out is the program output. The returned done
+// function closes the last source if the program stops before
+// reaching the end of the input.
+func (p *Program) inputs(stdin scan.Source, out io.Writer) (next func() (scan.Source, error), done func()) {
+	names := p.inputNames()
+	var prev io.Closer
+	done = func() {
+		if prev != nil {
+			prev.Close()
+			prev = nil
+		}
+	}
+	next = func() (scan.Source, error) {
+		done()
+		if err := p.closeOutput(out); err != nil {
+			return nil, err
+		}
+		for {
+			name, i, err := names()
+			if err != nil || name == "" {
+				return nil, err
+			}
+			src := stdin
+			if name != "-" {
+				if src, err = p.Open(name, i); err != nil {
+					if p.Warn == nil {
+						return nil, err
+					}
+					p.Warn(err)
+					continue
+				}
+				prev, _ = src.(io.Closer)
+			}
+			return src, p.openOutput(src.Name())
+		}
+	}
+	return next, done
+}
+
+// inputNames returns a function that returns the names of the
+// input files in ARGV one by one, with their indices in ARGV,
+// and the empty string after the last one. The name "-" stands
+// for stdin, which is also returned, with index 0, if ARGV names
+// no files. Assignments in ARGV are performed when they are
+// reached.
+func (p *Program) inputNames() func() (string, int, error) {
+	i, files := 1, 0
+	return func() (string, int, error) {
+		argv, ok := p.Get("ARGV").Array()
+		if !ok {
+			return "", 0, errors.New("ARGV is not an array")
+		}
+		argc, ok := p.Get("ARGC").Scalar()
+		if !ok {
+			return "", 0, errors.New("ARGC is not a scalar value")
+		}
+		for i < argc.Int() {
+			arg := argv.Get(value.NewInt(int64(i)))
+			i++
+			if arg == nil || arg.String() == "" {
+				continue
+			}
+			if _, _, ok := isAssignment(arg.String()); ok {
+				if err := p.Assign(arg.String()); err != nil {
+					return "", 0, err
+				}
+				continue
+			}
+			files++
+			return arg.String(), i - 1, nil
+		}
+		if files == 0 {
+			files++
+			return "-", 0, nil
+		}
+		return "", 0, nil
+	}
+}
+
+// Assign performs a var=value assignment given on the command
+// line. Escape sequences in value are replaced as in string
+// literals.
+func (p *Program) Assign(arg string) error {
+	name, val, ok := isAssignment(arg)
+	if !ok {
+		return fmt.Errorf("invalid assignment %q", arg)
+	}
+	val, err := unescape(val)
+	if err != nil {
+		return fmt.Errorf("assignment %q: %v", arg, err)
+	}
+	p.Put(name, value.NewStrNum(val))
+	p.assigned++
+	return nil
+}
+
+// isAssignment reports whether arg is a var=value assignment.
+func isAssignment(arg string) (name, val string, ok bool) {
+	for i, r := range arg {
+		switch {
+		case r == '=' && i > 0:
+			return arg[:i], arg[i+1:], true
+		case r == '_', isLetter(r), r >= utf8.RuneSelf:
+		case isDigit(r) && i > 0:
+		default:
+			return "", "", false
+		}
+	}
+	return "", "", false
+}
+
+// A closure is the implementation of a function value: a function
+// and, for function literals, the variables of the enclosing function
+// at the time the literal was evaluated.
+type closure struct {
+	fn    *FuncDecl
+	outer *frame
+}
+
+// funcValue returns the function value of the named function fn.
+// It is always the same value, so that fn equals itself.
+func funcValue(fn *FuncDecl) *value.Func {
+	if fn.val == nil {
+		fn.val = value.NewFunc(fn.Name, &closure{fn: fn})
+	}
+	return fn.val
+}
+
+// call calls the function value f with the arguments args
+// and returns its return value.
+func (p *Program) call(w io.Writer, f *value.Func, args []value.Value) value.Value {
+	c := f.Impl.(*closure)
+	fn := c.fn
+	fn.scope.Push(c.outer)
+	defer fn.scope.Pull()
+	for i, n := range fn.Args {
+		fn.scope.Put(n, args[i])
+	}
+	fn.Body.Exec(w)
+	if p.retval != nil {
+		v := p.retval
+		p.retval = nil
+		return v
+	}
+	return value.NewBool(false)
+}
+
+// lookupFunc returns the function value v, or the function named
+// by v if v is a string.
+func (p *Program) lookupFunc(v value.Value) (*value.Func, bool) {
+	if f, ok := v.(*value.Func); ok {
+		return f, true
+	}
+	if s, ok := v.(*value.Scalar); ok && s.Type() == value.String {
+		if fn, ok := p.funcs[s.String()]; ok {
+			return funcValue(fn), true
+		}
+	}
+	return nil, false
+}
+
+// A FuncDecl is a named function or a function literal,
+// in which case Name is empty.
 type FuncDecl struct {
+	debugInfo
 	scope *FuncScope
 	Name  string
 	Args  []string
 	Body  Stmt
+	val   *value.Func // of a named function, see funcValue
 }
 
+// A FuncScope holds the local variables of the calls of
+// a function.
 type FuncScope struct {
-	stack []map[string]value.Value
+	stack []*frame
 }
 
-func (f *FuncScope) Push() {
-	f.stack = append(f.stack, make(map[string]value.Value))
+// A frame holds the local variables of a function call.
+type frame struct {
+	vars  map[string]value.Value
+	outer *frame // of the enclosing function of a function literal
+}
+
+func (fr *frame) get(name string) value.Value {
+	if v, ok := fr.vars[name]; ok {
+		return v
+	}
+	v := &value.Undefined{}
+	fr.vars[name] = v
+	return v
+}
+
+// Push starts a new call. outer are the variables of the
+// enclosing function of a function literal.
+func (f *FuncScope) Push(outer *frame) {
+	f.stack = append(f.stack, &frame{make(map[string]value.Value), outer})
 }
 
 func (f *FuncScope) Pull() {
@@ -121,26 +495,44 @@ func (f *FuncScope) Pull() {
 }
 
 func (f *FuncScope) Get(name string) value.Value {
-	s := f.currScope()
-	if v, ok := s[name]; ok {
-		return v
-	}
-	v := &value.Undefined{}
-	s[name] = v
-	return v
+	return f.currFrame().get(name)
 }
 
 func (f *FuncScope) Put(name string, v value.Value) {
-	f.currScope()[name] = v
+	f.currFrame().vars[name] = v
 }
 
-func (f *FuncScope) currScope() map[string]value.Value {
+func (f *FuncScope) currFrame() *frame {
 	if f.stack == nil {
 		panic("stack shouldn't be nil")
 	}
 	return f.stack[len(f.stack)-1]
 }
 
+// An outerScope is the scope of the variables of an enclosing
+// function that are used in a function literal. depth is the
+// number of function literals nested in that function.
+type outerScope struct {
+	fn    *FuncScope // of the function literal
+	depth int
+}
+
+func (o *outerScope) frame() *frame {
+	fr := o.fn.currFrame()
+	for i := 0; i < o.depth; i++ {
+		fr = fr.outer
+	}
+	return fr
+}
+
+func (o *outerScope) Get(name string) value.Value {
+	return o.frame().get(name)
+}
+
+func (o *outerScope) Put(name string, v value.Value) {
+	o.frame().vars[name] = v
+}
+
 func throw(format string, args ...interface{}) {
 	panic(&runtimeError{fmt.Errorf(format, args...)})
 }
@@ -154,8 +546,100 @@ type debugInfo struct {
 	line    int
 }
 
-func genDebugInfo() debugInfo {
-	return debugInfo{progName, lexlineno}
+func genDebugInfo(yylex yyLexer) debugInfo {
+	l := yylex.(*yyLex)
+	return debugInfo{l.name, l.lineno}
+}
+
+// checkSwitch reports the errors in the case clauses of ss
+// that the grammar doesn't catch.
+func checkSwitch(yylex yyLexer, ss *SwitchStmt) {
+	def := false
+	for i, c := range ss.Cases {
+		switch {
+		case c.Exprs == nil && def:
+			c.error(yylex, "multiple defaults in switch")
+		case c.Regexp && ss.Tag == nil:
+			c.error(yylex, "case ~ in switch without an expression")
+		case c.Fallthrough && i == len(ss.Cases)-1:
+			c.error(yylex, "cannot fallthrough final case in switch")
+		}
+		def = def || c.Exprs == nil
+	}
+}
+
+// checkAssign reports an error unless x can be assigned to,
+// and returns x.
+func checkAssign(yylex yyLexer, x Expr) Expr {
+	switch x.(type) {
+	case *Ident, *IndexExpr, *FieldExpr:
+	default:
+		genDebugInfo(yylex).error(yylex, "cannot assign to a value that is not a variable, an array element or a field")
+	}
+	return x
+}
+
+// A label is a label of a statement being parsed.
+type label struct {
+	name  string
+	conts []*StatusStmt // continue statements referring to it
+}
+
+// pushLabel starts a statement labeled name. An empty name
+// starts a function literal, in which outer labels are not visible.
+func pushLabel(yylex yyLexer, name string) {
+	l := yylex.(*yyLex)
+	for i := len(l.labels) - 1; name != "" && i >= 0 && l.labels[i].name != ""; i-- {
+		if l.labels[i].name == name {
+			l.Errorf("label %s already defined", name)
+		}
+	}
+	l.labels = append(l.labels, &label{name: name})
+}
+
+// popLabel ends the labeled statement s, or a function literal
+// if s is nil, and returns s.
+func popLabel(yylex yyLexer, s Stmt) Stmt {
+	l := yylex.(*yyLex)
+	lb := l.labels[len(l.labels)-1]
+	l.labels = l.labels[:len(l.labels)-1]
+	switch s := s.(type) {
+	case *ForStmt:
+		s.Label = lb.name
+	case *ForeachStmt:
+		s.Label = lb.name
+	case *DoStmt:
+		s.Label = lb.name
+	case *SwitchStmt:
+		s.Label = lb.name
+		for _, c := range lb.conts {
+			c.error(yylex, "invalid continue label "+lb.name)
+		}
+	}
+	return s
+}
+
+// findLabel checks that the label of s is a label of an enclosing
+// statement.
+func findLabel(yylex yyLexer, s *StatusStmt) {
+	l := yylex.(*yyLex)
+	for i := len(l.labels) - 1; i >= 0 && l.labels[i].name != ""; i-- {
+		if lb := l.labels[i]; lb.name == s.Label {
+			if s.Status == StatusContinue {
+				lb.conts = append(lb.conts, s)
+			}
+			return
+		}
+	}
+	s.error(yylex, "label "+s.Label+" not defined")
+}
+
+// error reports a syntax error at di.
+func (di debugInfo) error(yylex yyLexer, msg string) {
+	l := yylex.(*yyLex)
+	if l.err == nil {
+		l.err = fmt.Errorf("%s:%d: %s", di.srcName, di.line, msg)
+	}
 }
 
 func (di debugInfo) throw(format string, args ...interface{}) {
@@ -1,11 +1,16 @@
 package compiler_test
 
 import (
+	"bytes"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"os"
 	"strings"
 	"testing"
 
 	"github.com/mibk/hawk/compiler"
+	"github.com/mibk/hawk/scan"
 )
 
 var valid = []struct {
@@ -19,6 +24,7 @@ var valid = []struct {
 	{`{} // `},
 	{`{ "\a\b\f\n\r\t\v\\\"'" }`},
 	{`{ '\a\b\f\n\r\t\v\\"\'' }`},
+	{`{ "\0" }`},
 }
 
 func TestValid(t *testing.T) {
@@ -36,7 +42,13 @@ var testProgs = []struct {
 }{
 	{`BEGIN {
 	} BEGIN`, "2: syntax error: unexpected BEGIN, expecting ';'"},
-	{`BEGIN { 00 = 20 }`, "1: syntax error: unexpected '=', expecting '}'"},
+	{`BEGIN { 00 = 20 }`, "1: cannot assign to a value that is not a variable, an array element or a field"},
+	{`BEGIN { a[1:] += 2 }`, "1: cannot assign to a value that is not a variable, an array element or a field"},
+	{`BEGIN { ++f() }`, "1: cannot assign to a value that is not a variable, an array element or a field"},
+	{`BEGIN { a = []; a[] += 1 }`, "1: x[] can only be used on the left of = to append"},
+	{`BEGIN { a = []
+		a[]++ }`, "2: x[] can only be used on the left of = to append"},
+	{`BEGIN { print a[] }`, "1: x[] can only be used on the left of = to append"},
 	{`/* `, "1: eof in block comment"},
 	{`" `, "1: eof in string literal"},
 	{`' `, "1: eof in string literal"},
@@ -46,6 +58,14 @@ var testProgs = []struct {
 		'`, "2: newline in string literal"},
 	{`"\e"`, `1: unknown escape character \e`},
 	{`"\i"`, `1: unknown escape character \i`},
+	{`BEGIN { for { break x } }`, "1: label x not defined"},
+	{`BEGIN { x: for { x: for {} } }`, "1: label x already defined"},
+	{`BEGIN { x: switch { default: for { continue x } } }`, "1: invalid continue label x"},
+	{`BEGIN { x: for { f = func() { break x } } }`, "1: label x not defined"},
+	{`func keys(a) { return 42 }; BEGIN { print keys([1]) }`, "1: cannot redeclare built-in function keys"},
+	{`func map(a, f) {}`, "1: cannot redeclare built-in function map"},
+	{`func join(a, sep) { return a . sep }`, "1: cannot redeclare built-in function join"},
+	{`func len(s) {}; BEGIN {}`, "1: cannot redeclare built-in function len"},
 }
 
 func TestErrors(t *testing.T) {
@@ -85,6 +105,9 @@ var runtimeInvalid = []struct {
 	14: {`[] ~ "regexp"`, "invalid types for regexp matching: array ~ string"},
 
 	15: {`print $-1`, "attempting to access a field using a negative index"},
+	16: {`patsplit("a", "(")`, "patsplit: invalid regexp"},
+	17: {`printf "%d", func() {}`, "printf: cannot format func literal using %d"},
+	18: {`x = sprintf("%s", func() {})`, "sprintf: cannot format func literal using %s"},
 }
 
 func TestRuntimeErrors(t *testing.T) {
@@ -127,3 +150,381 @@ func TestRuntimeValid(t *testing.T) {
 		}
 	}
 }
+
+var argsTests = []struct {
+	prog string
+	args []string
+	out  string
+	warn string
+}{
+	0: {`{ print FILENAME, FNR, $1 }`, []string{"a", "FS=:", "b"},
+		"a 1 one\na 2 three\nb 1 five\n", ""},
+	1: {`{ print FILENAME, $0 }`, nil, "stdin standard input\n", ""},
+	2: {`{ print FILENAME, $0 }`, []string{"x=1"}, "stdin standard input\n", ""},
+	3: {`{ print FILENAME, $0 }`, []string{"b", "-"}, "b five:six\nstdin standard input\n", ""},
+	4: {`BEGIN { ARGV[1] = ""; ARGV[ARGC] = "b"; ARGC++ }; { print FILENAME }`, []string{"a"}, "b\n", ""},
+	5: {`BEGIN { print ARGC, ARGV }`, []string{"a", "b"}, "3 [\"hawk\", \"a\", \"b\"]\n", ""},
+	6: {`END { print x }`, []string{"a", "x=7"}, "7\n", ""},
+	7: {`{ print x }; END { print x }`, []string{"x=1", "b", "x=2"}, "1\n2\n", ""},
+	8: {`END { print x }`, []string{`x=\ta\"b`, "b"}, "\ta\"b\n", ""},
+	9: {`{ print FILENAME }`, []string{"c", "a", "d", "b"}, "a\na\nb\n",
+		"open c: no such file\nopen d: no such file"},
+	10: {`{ print FILENAME }`, []string{"c"}, "", "open c: no such file"},
+}
+
+func TestArgs(t *testing.T) {
+	files := map[string]string{
+		"a": "one two\nthree four\n",
+		"b": "five:six\n",
+	}
+	open := func(name string, _ int) (scan.Source, error) {
+		s, ok := files[name]
+		if !ok {
+			return nil, fmt.Errorf("open %s: no such file", name)
+		}
+		return namedSource{strings.NewReader(s), name}, nil
+	}
+	for i, tt := range argsTests {
+		prog, err := compiler.Compile("args", strings.NewReader(tt.prog))
+		if err != nil {
+			t.Errorf("test %d: unexpected err: %v", i, err)
+			continue
+		}
+		prog.Args = tt.args
+		prog.Open = open
+		var warnings []string
+		prog.Warn = func(err error) { warnings = append(warnings, err.Error()) }
+		var out bytes.Buffer
+		stdin := namedSource{strings.NewReader("standard input\n"), "stdin"}
+		if err := prog.Run(&out, stdin); err != nil {
+			t.Errorf("test %d: unexpected err: %v", i, err)
+			continue
+		}
+		if got := out.String(); got != tt.out {
+			t.Errorf("test %d:\n got: %q\nwant: %q", i, got, tt.out)
+		}
+		if got := strings.Join(warnings, "\n"); got != tt.warn {
+			t.Errorf("test %d: warnings:\n got: %q\nwant: %q", i, got, tt.warn)
+		}
+	}
+
+	// Without Warn, the first missing file stops the program.
+	prog, err := compiler.Compile("args", strings.NewReader(`{ print }`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	prog.Args = []string{"a", "c", "b"}
+	prog.Open = open
+	var out bytes.Buffer
+	err = prog.Run(&out, nil)
+	if err == nil || err.Error() != "open c: no such file" {
+		t.Errorf("got err %v, want open c: no such file", err)
+	}
+	if got, want := out.String(), "one two\nthree four\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// Open is passed the index of each operand in ARGV.
+	prog, err = compiler.Compile("args", strings.NewReader(`{ print }`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	prog.Args = []string{"a", "x=1", "", "a"}
+	var indices []int
+	prog.Open = func(name string, i int) (scan.Source, error) {
+		indices = append(indices, i)
+		return open(name, i)
+	}
+	if err := prog.Run(io.Discard, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fmt.Sprint(indices), "[1 4]"; got != want {
+		t.Errorf("got indices %s, want %s", got, want)
+	}
+}
+
+type namedSource struct {
+	*strings.Reader
+	name string
+}
+
+func (s namedSource) Name() string { return s.name }
+
+// closeSource is a source that records whether it is closed.
+type closeSource struct {
+	namedSource
+	closed bool
+}
+
+func (s *closeSource) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestClose(t *testing.T) {
+	for _, src := range []string{`{ print }`, `{ print map($0, 1) }`} {
+		for _, n := range []int{0, 2} {
+			prog, err := compiler.Compile("close", strings.NewReader(src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			prog.Args = []string{"a", "-", "b"}
+			prog.Parallel = n
+			var opened []*closeSource
+			prog.Open = func(name string, _ int) (scan.Source, error) {
+				src := &closeSource{namedSource: namedSource{strings.NewReader(name + "\n"), name}}
+				opened = append(opened, src)
+				return src, nil
+			}
+			stdin := &closeSource{namedSource: namedSource{strings.NewReader("stdin\n"), "stdin"}}
+			err = prog.Run(io.Discard, stdin)
+			if fails := strings.Contains(src, "map"); fails != (err != nil) {
+				t.Fatalf("%s: parallel %d: unexpected err: %v", src, n, err)
+			}
+			if len(opened) == 0 {
+				t.Errorf("%s: parallel %d: nothing opened", src, n)
+			}
+			for _, o := range opened {
+				if !o.closed {
+					t.Errorf("%s: parallel %d: %s not closed", src, n, o.name)
+				}
+			}
+			if stdin.closed {
+				t.Errorf("%s: parallel %d: stdin closed", src, n)
+			}
+		}
+	}
+}
+
+func TestVars(t *testing.T) {
+	os.Setenv("HAWK_TEST", "env value")
+	prog, err := compiler.Compile("vars", strings.NewReader(`BEGIN { print x, y, ENVIRON["HAWK_TEST"] }`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	prog.Vars = []string{`x=a\nb`, "y=2", "x=3"}
+	var out bytes.Buffer
+	if err := prog.Run(&out, nil); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if got, want := out.String(), "3 2 env value\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	for _, v := range []string{"x", "=3", "1x=3", `x=\e`, `x=\`} {
+		prog, err := compiler.Compile("vars", strings.NewReader(`BEGIN {}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		prog.Vars = []string{v}
+		if err := prog.Run(ioutil.Discard, nil); err == nil {
+			t.Errorf("%q: expected an error", v)
+		}
+	}
+}
+
+func TestEncoding(t *testing.T) {
+	prog, err := compiler.Compile("enc", strings.NewReader(`{ print $2 }`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	prog.InputEncoding = "utf-16"
+	prog.OutputEncoding = "latin1"
+	var out bytes.Buffer
+	stdin := namedSource{strings.NewReader("\xff\xfea\x00 \x00\xe9\x00\n\x00"), "stdin"}
+	if err := prog.Run(&out, stdin); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if got, want := out.String(), "\xe9\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	prog.InputEncoding = "ebcdic"
+	if err := prog.Run(ioutil.Discard, nil); err == nil {
+		t.Error("expected an error for an unsupported encoding")
+	}
+}
+
+type closeBuffer struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (b *closeBuffer) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestOutput(t *testing.T) {
+	prog, err := compiler.Compile("output", strings.NewReader(
+		`BEGIN { print "begin" }; { print FILENAME, $0 }; END { print "end" }`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The file e is empty.
+	prog.Args = []string{"a", "e", "b"}
+	outputs := make(map[string]*closeBuffer)
+	prog.Open = func(name string, _ int) (scan.Source, error) {
+		// Each file is finished before the next one is opened.
+		for prev, b := range outputs {
+			if !b.closed {
+				t.Errorf("%s opened before the output of %s was closed", name, prev)
+			}
+		}
+		if name == "e" {
+			return namedSource{strings.NewReader(""), name}, nil
+		}
+		return namedSource{strings.NewReader(name + "1\n" + name + "2\n"), name}, nil
+	}
+	prog.Output = func(name string) (io.Writer, error) {
+		if outputs[name] != nil {
+			t.Errorf("%s: Output called twice", name)
+		}
+		outputs[name] = new(closeBuffer)
+		return outputs[name], nil
+	}
+	var out bytes.Buffer
+	if err := prog.Run(&out, nil); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if got, want := out.String(), "begin\nend\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	for _, name := range prog.Args {
+		b := outputs[name]
+		if b == nil {
+			t.Errorf("%s: no output", name)
+			continue
+		}
+		want := name + " " + name + "1\n" + name + " " + name + "2\n"
+		if name == "e" {
+			want = ""
+		}
+		if b.String() != want {
+			t.Errorf("%s: got %q, want %q", name, b.String(), want)
+		}
+		if !b.closed {
+			t.Errorf("%s: output not closed", name)
+		}
+	}
+}
+
+var parallelTests = []struct {
+	prog string
+	out  string
+}{
+	0: {`{ print FILENAME, $1 }`, "a 1\na 2\nb 3\nc 4\nc 5\nc 6\n"},
+	1: {`BEGIN { MERGE["n"] = MERGE["s"] = "sum" }; { n++; s += $1 }; END { print NR, n, s }`, "6 6 21\n"},
+	2: {`BEGIN { s = 100; MERGE["s"] = "sum" }; { s += $1 }; END { print s }`, "121\n"},
+	3: {`{ last = FILENAME; first = FILENAME; mx = FNR; mn = FNR }
+	    BEGIN { MERGE["first"] = "first"; MERGE["mx"] = "max"; MERGE["mn"] = "min" }
+	    END { print first, last, mn, mx }`, "a c 1 3\n"},
+	4: {`BEGIN { MERGE["count"] = "sum" }; { count[FILENAME]++ }; END { print count }`, `["a": 2, "b": 1, "c": 3]` + "\n"},
+	5: {`BEGIN { list = [0] }; { list = list + [$1] }; END { print list }`, `[0, "1", "2", "3", "4", "5", "6"]` + "\n"},
+	6: {`{ print x }; END { print x }`, "1\n1\n1\n2\n2\n2\n2\n"},
+	7: {`func adder(k) { return func(x) { return x + k } }
+	    BEGIN { f = adder(10); g = [func(x) { return -x }]; MERGE["s"] = "sum" }
+	    { s += f($1) + g[0]($1) }; END { print s, f(1) }`, "60 11\n"},
+	8: {`$1 > 2`, "3\n4\n5\n6\n"},
+	9: {`{ n++; m = FNR }; $1 == 3 { found = 1 }; END { print n, m, found }`, "3 3 1\n"},
+}
+
+func TestParallel(t *testing.T) {
+	files := map[string]string{
+		"a": "1\n2\n",
+		"b": "3\n",
+		"c": "4\n5\n6\n",
+	}
+	open := func(name string, _ int) (scan.Source, error) {
+		s, ok := files[name]
+		if !ok {
+			return nil, fmt.Errorf("open %s: no such file", name)
+		}
+		return namedSource{strings.NewReader(s), name}, nil
+	}
+	for i, tt := range parallelTests {
+		for _, n := range []int{2, 8} {
+			prog, err := compiler.Compile("parallel", strings.NewReader(tt.prog))
+			if err != nil {
+				t.Fatalf("test %d: unexpected err: %v", i, err)
+			}
+			prog.Args = []string{"x=1", "a", "b", "x=2", "c"}
+			prog.Open = open
+			prog.Parallel = n
+			var out bytes.Buffer
+			if err := prog.Run(&out, nil); err != nil {
+				t.Errorf("test %d (%d): unexpected err: %v", i, n, err)
+				continue
+			}
+			if got := out.String(); got != tt.out {
+				t.Errorf("test %d (%d):\n got: %q\nwant: %q", i, n, got, tt.out)
+			}
+		}
+	}
+
+	// The output is written up to the first runtime error.
+	prog, err := compiler.Compile("parallel", strings.NewReader(`{ print $1 }; FILENAME == "b" { print $-1 }`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	prog.Args = []string{"a", "b", "c"}
+	prog.Open = open
+	prog.Parallel = 2
+	var out bytes.Buffer
+	err = prog.Run(&out, nil)
+	if want := "parallel:1: attempting to access a field using a negative index"; err == nil || err.Error() != want {
+		t.Errorf("got err %v, want %s", err, want)
+	}
+	if got, want := out.String(), "1\n2\n3\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	prog, err = compiler.Compile("parallel", strings.NewReader(`BEGIN { MERGE["x"] = "avg" }; {}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	prog.Parallel = 2
+	if err := prog.Run(ioutil.Discard, nil); err == nil {
+		t.Error("expected an error for an unknown merge strategy")
+	}
+}
+
+var bignumTests = []struct {
+	prog string
+	out  string
+}{
+	0:  {`print 14.67 + 0.01, 0.1 + 0.2 == 0.3`, "14.68 true"},
+	1:  {`print 9007199254740993 * 1000 + 1`, "9007199254740993001"},
+	2:  {`print 10 / 4, 10 / 4 * 4, 1 / 3`, "2.5 10 0.33333333"},
+	3:  {`print 7 % 3, -(1 / 2), 2.5 % 2`, "1 -0.5 0"},
+	4:  {`print sqrt(16), sqrt(2)`, "4 1.414213562373095"},
+	5:  {`PREC = 100; print sqrt(2), PREC`, "1.414213562373095048801688724209 100"},
+	6:  {`printf "%.20f %d %x", 1 / 3, 10 / 3, 255`, "0.33333333333333333333 3 ff"},
+	7:  {`printf "%.2f %.1f %08.3f|%6.2f", 2.675, 0.25, -2.5, 1`, "2.68 0.3 -002.500|  1.00"},
+	8:  {`printf "%s %v %d", "007", "007", "007"`, "007 007 7"},
+	9:  {`print 1 / 0, 1 + 1 / 0`, "+Inf +Inf"},
+	10: {`x[1 / 2 * 2] = "a"; print x[1]`, "a"},
+	11: {`print 2 ** 100, 3 ** -2, 0.1 ** 3, 2 ** 0.5`, "1267650600228229401496703205376 0.11111111 0.001 1.4142136"},
+	12: {`print 1 << 70 | 1, ^(1 << 64), 2 ** 65 >> 64, 7.9 & 3`, "1180591620717411303425 -18446744073709551617 2 3"},
+	13: {`PREC = 100; printf "%.25f %.25f %.25f %.25f %.25f", exp(1), log(2), sin(1), cos(-7), atan2(0, -1)`,
+		"2.7182818284590452353602875 0.6931471805599453094172321 0.8414709848078965066525023 0.7539022543433046381411975 3.1415926535897932384626434"},
+}
+
+func TestBignum(t *testing.T) {
+	for i, tt := range bignumTests {
+		prog, err := compiler.Compile("bignum", strings.NewReader("BEGIN { "+tt.prog+" }"))
+		if err != nil {
+			t.Errorf("test %d: unexpected err: %v", i, err)
+			continue
+		}
+		prog.Bignum = true
+		var out bytes.Buffer
+		if err := prog.Run(&out, nil); err != nil {
+			t.Errorf("test %d: unexpected err: %v", i, err)
+			continue
+		}
+		if got := strings.TrimSuffix(out.String(), "\n"); got != tt.out {
+			t.Errorf("test %d:\n got: %q\nwant: %q", i, got, tt.out)
+		}
+	}
+}
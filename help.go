@@ -31,29 +31,51 @@ There are two forms of comments: // line comments, and /* block comments */.
 	statement syntax has a different keyword (in). There are two additional
 	statements for printing (print and printf). The pipe statement pipes the output
 	of a statement through an external program given in a string constant.
+	A switch statement compares its expression with the case expressions like
+	the == operator does; "case ~" matches it against regular expressions.
 
 	if expr { statements }
 
 	if expr { statements } else ...
 
+	switch opt_expr {
+	case expr_list:
+		statements
+		fallthrough // optional
+	case ~ expr_list: // regexp matching
+		statements
+	default:
+		statements
+	}
+
 	for opt_expr; opt_expr; opt_expr { statements }
 
 	for opt_expr { statements } // like C's while
 
 	for var in array { statements }
 
-	continue
+	do { statements } while expr
+
+	label: for ... // or do or switch
+
+	continue opt_label
 
-	break
+	break opt_label
 
-	statement | "command" // pipe statement
+	statement | "command" // pipe statement; the command must be a string literal
 
 	print expression_list or printf format, expression_list
 
 
-	assignment operators: =  +=  -=  *=  /=  %=
+	assignment operators: =  +=  -=  *=  /=  %=  .=  **=
+	                      &=  |=  ^=  &^=  <<=  >>=
 
-	post inc and dec:     ++  --
+	inc and dec:          ++  --  (both prefix and postfix)
+
+	Assignments and inc and dec are expressions, as in C: x = y = 0 sets both
+	x and y, and a[n++] = $1 appends to a. Fields can be assigned to, as in Awk:
+	setting a field rebuilds $0 by joining the fields with OFS, and setting $0
+	splits it into fields again.
 
 
 3. Expressions
@@ -69,49 +91,183 @@ There are two forms of comments: // line comments, and /* block comments */.
 	relational:         <  >  <=  >=  ==  !=
 	regexp matching:    ~  !~
 	concatenation:      .
-	add operations:     +  -
-	mul operations:     *  /  %
-	unary:              +  -
+	add operations:     +  -  |  ^
+	mul operations:     *  /  %  <<  >>  &  &^
+	unary:              +  -  ^
 	logical not:        !
+	exponentiation:     **  (right associative, so 2 ** 3 ** 2 is 512)
+	index and slice:    a[i]  a[i:j]  (and postfix ++ and --)
 	field:              $
 
+	The bitwise operators work on the integer parts of numbers, like in Go.
+	A | followed by a string literal always starts a pipe statement, so a
+	bitwise or with a string literal is not possible as such: x | "3" must
+	be written as x | ("3").
+
+	Strings can be indexed and sliced by characters, and non-associative arrays
+	can be sliced; s[i] is the i-th character of s, and s[i:j] and a[i:j] are
+	the characters or values from i up to, but not including, j. Both i and j
+	are optional in slices, and negative indexes count from the end. Any operand
+	can be indexed or sliced, so $1[0] is the first character of the first field,
+	and f()[1:] is the result of f without its first value. $ applies to the operand
+	that follows it, except for calls: $i++ increments the field $i, as in Awk, and
+	$f(x) is the field f(x). The field a[i] must be written as $(a[i]).
+
 
 4. Data types
 
-Hawk has 3 basic data types: strings, numbers and booleans. All arithmetic operations are
-done using floating-point arithmetics.
+Hawk has 3 basic data types: strings, numbers and booleans. Numbers are either 64-bit
+integers or floats. Integer literals and strings holding integers, such as fields, are
+integers; addition, subtraction, multiplication and remainder of two integers give an
+integer unless the result overflows, in which case it is a float. Division always gives
+a float.
+
+Values that come from the input, such as fields, patsplit elements, ARGV and ENVIRON
+elements and var=value assignments, are numeric strings if they look like decimal
+numbers. Two values are compared as numbers if both of them are numbers, booleans or
+numeric strings, and as strings otherwise; so $1 > 200 is a numeric comparison if $1
+is 1000, but "abc" == 0 is false. Array keys are compared as strings: m[1], m["1"]
+and m[$1] are the same element if $1 is "1", but m["01"] and m[true] are not.
+
+With -M, arithmetic operations use arbitrary-precision rational numbers instead, so that
+14.67 + 0.01 is exactly 14.68. Values with a finite decimal representation are printed
+exactly, and the integer and fixed-point verbs of printf are exact too. The arithmetic
+functions are computed with the precision of PREC bits. The exact results of ** and <<
+are limited to 4194304 bits; larger ones are runtime errors.
 
 	boolean:  true  false
 	number:   12  12.38  0xFF  0Xba
-	string:   "double\nquotes"  'single \'quotes\''` + "  `raw strings with ``escaped`` back-quotes`" + `
+	string:   "double\nquotes"  'single \'quotes\''  "NUL\0byte"` + "  `raw strings with ``escaped`` back-quotes`" + `
+	function: func(x) { return x * 2 }
+
+Functions are values too: named functions and function literals can be stored in
+variables and arrays, passed to functions, and called, e.g. f(x) or fs[0](x). The
+parameters of a function literal are its local variables; its other variables are
+those of the enclosing function, even after the enclosing function returns, or global
+variables outside of functions.
 
 
 5. Built-in variables
 
+	ARGC       number of elements in ARGV
+
+	ARGV       array of command-line operands; ARGV[0] is "hawk", input files
+	           and var=value assignments follow (they can be changed in BEGIN)
+
+	CONVFMT    format of non-integral numbers converted to strings, e.g. by
+	           concatenation or as array keys (default is "%.8g")
+
+	FIELDWIDTHS splits records into fields of fixed widths, e.g. "5 3 *"
+	           (* is the rest of the record); setting FS or FPAT switches
+	           it off
+
+	ENVIRON    array of environment variables
+
 	FILENAME   name of the current input file
 
 	FNR        current record number in FILENAME
 
-	FS         splits records into fields using FS as a regexp
+	FPAT       splits records into fields matching FPAT as a regexp
+	           (leftmost longest); setting FS or FIELDWIDTHS switches it off
+
+	FS         splits records into fields using FS as a regexp; a single
+	           character is taken literally
+
+	MERGE      array of merge strategies for -parallel: MERGE["var"] is "sum",
+	           "min", "max", "first" or "last"; by default, values are
+	           taken from the last file that changed them, so counters
+	           must be declared as "sum"; arrays are merged element by
+	           element
 
 	NF         number of fields in the current record
 
-	NR         current number of records in the whole input stream
+	NR         current number of records in the whole input stream; with
+	           -parallel, only in the current file until END
+
+	OFMT       format of non-integral numbers printed by print (default is
+	           "%.8g"); integral numbers are always printed as integers
 
 	OFS        output fields separator (default is " ")
 
 	ORS        output record separator (default is "\n")
 
-	RS         splits input into records using RS as a regexp
+	PREC       precision of the arithmetic functions in bits with -M (default is 53)
+
+	RS         splits input into records using RS as a regexp; if RS is
+	           empty, records are separated by blank lines and newline
+	           separates fields in addition to FS
+
+	RT         the text that terminated the current record
+
+	SORTED_IN  order of "for k, v in array" loops: "@ind_str_asc",
+	           "@ind_num_asc", "@val_str_asc" or "@val_num_asc" sort by
+	           keys or values as strings or numbers ("_desc" instead of
+	           "_asc" reverses the order); a function, or the name of one,
+	           f(k1, v1, k2, v2) returning a negative number, zero or
+	           a positive number sorts using the function; by default, or
+	           if it is "@unsorted" or empty, the order of insertion is used
 
 
 6. Built-in functions
 
+The names of the built-in functions are reserved: declaring a function named like
+one, e.g. func keys(a), is an error. Programs written for earlier versions of Hawk
+that declare functions named patsplit, keys, values, sort, asort, asorti, reverse,
+slice, join, map, filter or reduce must rename them.
+
 	len(expr)   returns the length of a string or the count of items in an array
 
+	patsplit(s, fpat)
+	            returns an array of all the parts of s matching the regexp fpat
+
 	sprintf(format, ...expr)
 
 
+	Array functions (the arrays returned are new and non-associative):
+
+	asort(a[, order])
+	            returns the values of a in the order given as for SORTED_IN,
+	            or sorted as by sort
+
+	asorti(a[, order])
+	            returns the keys of a in the order given as for SORTED_IN
+	            (default is "@ind_str_asc")
+
+	join(a, sep)
+	            returns the values of a joined by sep
+
+	keys(a)     returns the keys of a
+
+	reverse(a)  returns the values of a in reverse order; reverse(s) returns
+	            the string s reversed
+
+	slice(a, i[, j])
+	            returns the values of a from position i up to, but not
+	            including, j (default is the end); negative positions count
+	            from the end; slice(s, i[, j]) returns the characters of s
+
+	sort(a[, f])
+	            returns the values of a sorted as by the comparison
+	            operators, or by the function f: f(x, y) returns
+	            a negative number, zero or a positive number if x is
+	            ordered before, as or after y
+
+	values(a)   returns the values of a
+
+
+	Functions taking functions (f can be a function or the name of one):
+
+	filter(a, f)
+	            returns the elements of a for which f(v) is true
+
+	map(a, f)   returns the elements of a with each value v replaced by f(v)
+
+	reduce(a, f, init)
+	            returns f(...f(f(init, v1), v2)..., vn) for the values of a
+
+	(filter and map keep the keys of associative arrays.)
+
+
 	Arithmetic functions:
 
 	atan2(x, y)
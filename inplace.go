@@ -0,0 +1,111 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// inPlaceFlag is the -i[suffix] flag. The flag package doesn't support
+// optional values, so inPlaceArgs rewrites the flag to -i=suffix.
+type inPlaceFlag struct {
+	set    bool
+	suffix string
+}
+
+func (f *inPlaceFlag) String() string   { return f.suffix }
+func (f *inPlaceFlag) IsBoolFlag() bool { return true }
+
+func (f *inPlaceFlag) Set(s string) error {
+	f.set, f.suffix = true, s
+	return nil
+}
+
+// inPlaceArgs returns args with -i and -isuffix replaced by
+// -i= and -i=suffix. Flags such as -ienc are left as they are.
+func inPlaceArgs(args []string) []string {
+	args = append([]string(nil), args...)
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if a == "--" || len(a) < 2 || a[0] != '-' {
+			break
+		}
+		name := strings.TrimLeft(a, "-")
+		if strings.Contains(name, "=") {
+			continue
+		}
+		if a == "-i" || strings.HasPrefix(a, "-i") && flag.Lookup(name) == nil {
+			args[i] = "-i=" + a[2:]
+			continue
+		}
+		if f := flag.Lookup(name); f != nil && !isBoolFlag(f) {
+			i++ // skip the value
+		}
+	}
+	return args
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// inPlaceFile is the output of an input file edited in place.
+// It is written to a temporary file in the same directory that
+// replaces the input file when it is closed.
+type inPlaceFile struct {
+	*os.File
+	name   string
+	suffix string
+}
+
+// createInPlace creates the output for the input file name.
+func createInPlace(name, suffix string) (*inPlaceFile, error) {
+	fi, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(name), "."+filepath.Base(name)+".hawk")
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Chmod(fi.Mode().Perm()); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return &inPlaceFile{f, name, suffix}, nil
+}
+
+// Close replaces the input file with the output. If suffix is
+// not empty, the original file is kept with the suffix appended
+// to its name.
+func (f *inPlaceFile) Close() error {
+	if err := f.File.Close(); err != nil {
+		os.Remove(f.File.Name())
+		return err
+	}
+	if f.suffix != "" {
+		backup := f.name + f.suffix
+		os.Remove(backup)
+		if err := os.Link(f.name, backup); err != nil {
+			if err := os.Rename(f.name, backup); err != nil {
+				os.Remove(f.File.Name())
+				return err
+			}
+		}
+	}
+	if err := os.Rename(f.File.Name(), f.name); err != nil {
+		os.Remove(f.File.Name())
+		return err
+	}
+	return nil
+}
+
+// Abort removes the output, keeping the input file unchanged.
+func (f *inPlaceFile) Abort() {
+	f.File.Close()
+	os.Remove(f.File.Name())
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var inPlaceArgsTests = []struct {
+	args []string
+	want []string
+}{
+	0: {[]string{"-i", "{}", "a"}, []string{"-i=", "{}", "a"}},
+	1: {[]string{"-i.bak", "{}"}, []string{"-i=.bak", "{}"}},
+	2: {[]string{"-ienc", "latin1", "-i~"}, []string{"-ienc", "latin1", "-i=~"}},
+	3: {[]string{"-F", "-i", "-i"}, []string{"-F", "-i", "-i="}},
+	4: {[]string{"-raw", "-i"}, []string{"-raw", "-i="}},
+	5: {[]string{"{}", "-i"}, []string{"{}", "-i"}},
+	6: {[]string{"--", "-i"}, []string{"--", "-i"}},
+	7: {[]string{"-i=x"}, []string{"-i=x"}},
+}
+
+func TestInPlaceArgs(t *testing.T) {
+	for i, tt := range inPlaceArgsTests {
+		if got := inPlaceArgs(tt.args); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("test %d:\n got: %q\nwant: %q", i, got, tt.want)
+		}
+	}
+}
+
+func TestInPlaceFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hawk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "conf")
+	if err := ioutil.WriteFile(name, []byte("old\n"), 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := createInPlace(name, ".bak")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("new\n")
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for file, want := range map[string]string{name: "new\n", name + ".bak": "old\n"} {
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != want {
+			t.Errorf("%s: got %q, want %q", file, b, want)
+		}
+	}
+	if fi, err := os.Stat(name); err != nil || fi.Mode().Perm() != 0640 {
+		t.Errorf("got mode %v, want 0640 (err: %v)", fi.Mode(), err)
+	}
+
+	f, err = createInPlace(name, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("aborted\n")
+	f.Abort()
+	if b, _ := ioutil.ReadFile(name); string(b) != "new\n" {
+		t.Errorf("after Abort: got %q, want %q", b, "new\n")
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 2 {
+		t.Errorf("got %d files, want 2", len(files))
+	}
+}
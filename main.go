@@ -6,7 +6,9 @@ import (
 	"io"
 	"log"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 
 	"github.com/mibk/hawk/compiler"
 	"github.com/mibk/hawk/scan"
@@ -17,16 +19,41 @@ var (
 
 	file     = flag.String("f", "", "read program from `file`")
 	fieldSep = flag.String("F", "", "set the field separator, FS")
+	nulSep   = flag.Bool("0", false, "separate input records by NUL bytes, e.g. for find -print0")
+	raw      = flag.Bool("raw", false, "don't decompress gzip, bzip2 and zlib input files")
+	follow   = flag.Bool("follow", false, "keep reading the last input file as it grows, like tail -F")
+	inputEnc = flag.String("ienc", "", "convert input from `encoding` (utf-16, utf-16le, utf-16be, latin1) to UTF-8")
+	outEnc   = flag.String("oenc", "", "convert output from UTF-8 to `encoding`")
+	bignum   = flag.Bool("M", false, "use arbitrary-precision arithmetic")
+	parallel = flag.Int("parallel", 1, "process up to `n` input files concurrently")
+
+	vars    assignments
+	inPlace inPlaceFlag
 )
 
+func init() {
+	flag.Var(&vars, "v", "assign `var=value` before running the program (can be repeated)")
+	flag.Var(&inPlace, "i", "edit the input files in place; -isuffix keeps backups with suffix appended to their names")
+}
+
+// assignments is a flag.Value collecting var=value assignments.
+type assignments []string
+
+func (a *assignments) String() string { return strings.Join(*a, " ") }
+
+func (a *assignments) Set(s string) error {
+	*a = append(*a, s)
+	return nil
+}
+
 func main() {
 	log.SetFlags(0)
 	log.SetPrefix("hawk: ")
 	flag.Usage = usage
-	flag.Parse()
+	flag.CommandLine.Parse(inPlaceArgs(os.Args[1:]))
 
 	if *helpFlag {
-		fmt.Fprint(os.Stderr, extendedHelp)
+		io.WriteString(os.Stderr, extendedHelp)
 		os.Exit(2)
 	}
 
@@ -50,18 +77,19 @@ func main() {
 		args = args[1:]
 	}
 
-	var input scan.Source = os.Stdin
-	if len(args) > 0 {
-		srcs := make([]scan.Source, 0, len(args))
-		for _, file := range args {
-			f, err := os.Open(file)
-			if err != nil {
-				log.Fatal(err)
-			}
-			defer f.Close()
-			srcs = append(srcs, f)
+	if *follow && len(args) == 0 {
+		log.Fatal("-follow requires an input file")
+	}
+	if *parallel > 1 && (*follow || inPlace.set) {
+		log.Fatal("-parallel cannot be used with -follow or -i")
+	}
+	if inPlace.set {
+		if len(args) == 0 {
+			log.Fatal("-i requires an input file")
+		}
+		if *follow {
+			log.Fatal("-i cannot be used with -follow")
 		}
-		input = scan.MultiSource(srcs...)
 	}
 
 	prog, err := compiler.Compile(name, srcCode)
@@ -69,17 +97,111 @@ func main() {
 		log.Fatal(err)
 	}
 	prog.FieldSep = *fieldSep
-	if err := prog.Run(os.Stdout, input); err != nil {
+	if *nulSep {
+		prog.RowSep = "\x00"
+	}
+	prog.InputEncoding = *inputEnc
+	prog.OutputEncoding = *outEnc
+	prog.Vars = vars
+	prog.Bignum = *bignum
+	prog.Parallel = *parallel
+	prog.Args = args
+	prog.Open = func(name string, i int) (scan.Source, error) {
+		// Only the last operand is followed, even if
+		// the same file is named more than once.
+		return openInput(name, *follow && i == len(args))
+	}
+	failed := false
+	prog.Warn = func(err error) {
+		log.Print(err)
+		failed = true
+	}
+	var editing *inPlaceFile
+	if inPlace.set {
+		prog.Output = func(name string) (io.Writer, error) {
+			if !opened[name] {
+				return struct{ io.Writer }{os.Stdout}, nil // stdin
+			}
+			f, err := createInPlace(name, inPlace.suffix)
+			if err != nil {
+				return nil, err
+			}
+			editing = f
+			return f, nil
+		}
+	}
+	if err := prog.Run(os.Stdout, os.Stdin); err != nil {
+		if editing != nil {
+			editing.Abort()
+		}
 		log.Fatal(err)
 	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// opened records the input files opened by openInput.
+var opened = make(map[string]bool)
+
+// openInput opens the input file name. It is followed if follow
+// is set; otherwise, it is decompressed unless -raw or -i is set.
+func openInput(name string, follow bool) (scan.Source, error) {
+	if follow {
+		fw, err := scan.Follow(name)
+		if err != nil {
+			return nil, err
+		}
+		stopOnSignal(fw)
+		return fw, nil
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if fi, err := f.Stat(); err != nil || fi.IsDir() {
+		f.Close()
+		if err == nil {
+			err = fmt.Errorf("%s: is a directory", name)
+		}
+		return nil, err
+	}
+	opened[name] = true
+	if *raw || inPlace.set {
+		return f, nil
+	}
+	src, err := scan.Decompress(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return src, nil
+}
+
+// stopOnSignal stops following the input on SIGINT or SIGTERM
+// so that the END actions are still run. A second signal
+// terminates hawk right away.
+func stopOnSignal(fw *scan.Follower) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-c
+		signal.Stop(c)
+		fw.Stop()
+	}()
 }
 
-const shortHelp = `Usage: hawk 'program' [file ...]
-  or:  hawk -f progfile [file ...]
+const shortHelp = `Usage: hawk 'program' [file | var=value ...]
+  or:  hawk -f progfile [file | var=value ...]
 
 Hawk is an Awk clone. Program is a set of pattern {action} pairs. Hawk reads
 from all of the present files and for each line of each file executes all the
-provided pairs. If no files are present, hawk reads from stdin.
+provided pairs. If no files are present, or the file is -, hawk reads from
+stdin. An operand var=value assigns value to var when hawk reaches it, so it
+can change e.g. FS between files. Input files compressed using gzip, bzip2
+or zlib are decompressed on the fly. With -i, the output of the actions run
+for the records of a file replaces the file; BEGIN and END still print to
+stdout.
 `
 
 func usage() {
@@ -0,0 +1,15 @@
+package scan
+
+import "github.com/mibk/hawk/charset"
+
+// Decode returns a Source that converts src from the encoding enc
+// to UTF-8. See package charset for the supported encodings. The
+// returned Source has the same name as src, and closing it closes
+// src if src implements io.Closer.
+func Decode(src Source, enc string) (Source, error) {
+	r, err := charset.NewDecoder(src, enc)
+	if err != nil {
+		return nil, err
+	}
+	return &filteredSource{r, src}, nil
+}
@@ -0,0 +1,72 @@
+package scan
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+)
+
+// Decompress returns a Source that transparently decompresses src
+// if it is compressed using gzip, bzip2 or zlib. The compression
+// is detected by the magic bytes at the beginning of src. Sources
+// that are not compressed are read as they are. The returned
+// Source has the same name as src, and closing it closes src if
+// src implements io.Closer.
+func Decompress(src Source) (Source, error) {
+	br := bufio.NewReader(src)
+	magic, err := br.Peek(4)
+	if err == io.EOF {
+		err = nil // shorter than any magic
+	} else if err != nil {
+		return nil, err
+	}
+	var r io.Reader = br
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		r, err = gzip.NewReader(br)
+	case len(magic) == 4 && bytes.HasPrefix(magic, []byte("BZh")) && magic[3] >= '1' && magic[3] <= '9':
+		r = bzip2.NewReader(br)
+	case isZlibHeader(magic):
+		r, err = zlib.NewReader(br)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &filteredSource{r, src}, nil
+}
+
+// isZlibHeader reports whether b starts with a zlib header as
+// written by common compressors: deflate with a 32K window and
+// no preset dictionary. The header of the "fast" level, "x^",
+// is left out as it is too likely to be the start of a text.
+func isZlibHeader(b []byte) bool {
+	if len(b) < 2 || b[0] != 0x78 {
+		return false
+	}
+	switch b[1] {
+	case 0x01, 0x9c, 0xda:
+		return true
+	}
+	return false
+}
+
+// filteredSource reads src through a decoding Reader.
+type filteredSource struct {
+	io.Reader
+	src Source
+}
+
+func (d *filteredSource) Name() string { return d.src.Name() }
+
+func (d *filteredSource) Close() error {
+	if c, ok := d.Reader.(io.Closer); ok {
+		c.Close()
+	}
+	if c, ok := d.src.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
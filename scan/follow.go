@@ -0,0 +1,101 @@
+package scan
+
+import (
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// pollInterval is how often a Follower checks for new data.
+var pollInterval = 250 * time.Millisecond
+
+// A Follower is a Source that reads a growing file like tail -F.
+// Instead of returning io.EOF at the end of the file, it waits for
+// more data to be appended. If the file is truncated, it starts
+// reading from the beginning again; if the file is replaced by
+// a new one (e.g. by a log rotation), it switches to the new file.
+// Read returns io.EOF only after Stop is called.
+type Follower struct {
+	name string
+	f    *os.File
+	off  int64
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// Follow opens the named file for following.
+func Follow(name string) (*Follower, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &Follower{name: name, f: f, stop: make(chan struct{})}, nil
+}
+
+func (fw *Follower) Name() string { return fw.name }
+
+func (fw *Follower) Read(p []byte) (n int, err error) {
+	for {
+		select {
+		case <-fw.stop:
+			return 0, io.EOF
+		default:
+		}
+		n, err = fw.f.Read(p)
+		fw.off += int64(n)
+		if n > 0 || err != io.EOF {
+			return n, err
+		}
+		if err := fw.reopen(); err != nil {
+			return 0, err
+		}
+		select {
+		case <-fw.stop:
+			return 0, io.EOF
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// reopen handles truncation and replacement of the followed file.
+// It must be called only after the current file has been read
+// to the end.
+func (fw *Follower) reopen() error {
+	fi, err := fw.f.Stat()
+	if err != nil {
+		return err
+	}
+	if fi.Size() < fw.off {
+		// Truncated.
+		if _, err := fw.f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		fw.off = 0
+		return nil
+	}
+	nfi, err := os.Stat(fw.name)
+	if err != nil || os.SameFile(fi, nfi) {
+		// Not replaced, or the new file doesn't exist yet.
+		return nil
+	}
+	f, err := os.Open(fw.name)
+	if err != nil {
+		return nil // try again later
+	}
+	fw.f.Close()
+	fw.f, fw.off = f, 0
+	return nil
+}
+
+// Stop makes Read return io.EOF. It is safe to call Stop
+// from another goroutine, and to call it more than once.
+func (fw *Follower) Stop() {
+	fw.stopOnce.Do(func() { close(fw.stop) })
+}
+
+// Close closes the currently followed file.
+func (fw *Follower) Close() error {
+	return fw.f.Close()
+}
@@ -2,16 +2,20 @@ package scan
 
 import (
 	"bufio"
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"regexp"
+	"regexp/syntax"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 // endOfSource is returned when io.EOF is reached in one of
-// the sources and there are still more sources to process.
+// the sources. The sources that follow might still have data
+// to read.
 var endOfSource = errors.New("end of source")
 
 // Source is the interface thats wraps io.Reader and provides
@@ -26,22 +30,56 @@ type Source interface {
 // MultiSource returns a Source that's the logical concatenation
 // of the provided input sources.
 func MultiSource(sources ...Source) Source {
-	return &multiSource{sources}
+	return LazyMultiSource(func() (Source, error) {
+		if len(sources) == 0 {
+			return nil, nil
+		}
+		src := sources[0]
+		sources = sources[1:]
+		return src, nil
+	})
+}
+
+// LazyMultiSource returns a Source that's the logical concatenation
+// of the sources returned by next. next is called only when all
+// the data of the previous source has been read, so it can open
+// the sources on demand. It returns a nil Source if there are no
+// more sources. The sources are not closed; next can close the
+// previous source it opened when it is called. Name of the returned
+// Source is the name of the last source obtained from next.
+func LazyMultiSource(next func() (Source, error)) Source {
+	return &multiSource{next: next}
 }
 
 type multiSource struct {
-	sources []Source
+	next func() (Source, error)
+	cur  Source
+	name string
+	done bool
 }
 
 func (ms *multiSource) Read(p []byte) (n int, err error) {
-	for len(ms.sources) > 0 {
-		n, err = ms.sources[0].Read(p)
+	for !ms.done {
+		if ms.cur == nil {
+			src, err := ms.next()
+			if err != nil {
+				return 0, err
+			}
+			if src == nil {
+				ms.done = true
+				break
+			}
+			ms.cur, ms.name = src, src.Name()
+		}
+		n, err = ms.cur.Read(p)
 		if err == io.EOF {
-			ms.sources = ms.sources[1:]
-			if len(ms.sources) > 0 {
-				err = endOfSource
-				return
+			if n > 0 {
+				// Report the end of source with the next Read
+				// to keep its data apart from the next source.
+				return n, nil
 			}
+			ms.cur = nil
+			return 0, endOfSource
 		}
 		if n > 0 || err != nil {
 			return
@@ -50,66 +88,175 @@ func (ms *multiSource) Read(p []byte) (n int, err error) {
 	return 0, io.EOF
 }
 
-func (ms *multiSource) Name() string {
-	if len(ms.sources) == 0 {
-		return ""
-	}
-	return ms.sources[0].Name()
-}
+func (ms *multiSource) Name() string { return ms.name }
 
 // A Scanner is used for splitting input into rows and
 // splitting rows into fields.
 type Scanner struct {
 	lr       lineReader
-	rowsRx   *regexp.Regexp
+	rowsRx   *regexp.Regexp // nil if rows are separated by rowSep
+	rowSep   string         // literal row separator
 	fieldsRx *regexp.Regexp
-	err      error // sticky err
+	fieldPat *regexp.Regexp // matches the fields themselves
+	widths   []int          // fixed field widths; restOfLine stands for '*'
+	err      error          // sticky err
+
+	// In paragraph mode, rows are separated by blank lines
+	// and newline separates fields in addition to fieldsRx.
+	paragraph bool
+	paraSepRx *regexp.Regexp // fieldsRx or newline
 
 	recNumber     int
 	fileRecNumber int
 	rec           string
+	rt            string // actual row terminator
 	fields        []string
 }
 
 // SetSource sets a Source for scanner to read from.
 func (sc *Scanner) SetSource(src Source) {
+	sc.lr = sc.newLineReader(src)
+	sc.recNumber, sc.fileRecNumber = 0, 0
+}
+
+func (sc *Scanner) newLineReader(src Source) lineReader {
 	if sc.rowsRx != nil {
-		sc.lr = newRxLineReader(src, sc.rowsRx)
-	} else {
-		sc.lr = newSimpleLineReader(src)
+		return newRxLineReader(src, sc.rowsRx)
+	}
+	return newSimpleLineReader(src, sc.rowSep)
+}
+
+// CopySettings sets the row and field separators of sc to
+// those of sc2.
+func (sc *Scanner) CopySettings(sc2 *Scanner) {
+	sc.rowsRx = sc2.rowsRx
+	sc.rowSep = sc2.rowSep
+	sc.fieldsRx = sc2.fieldsRx
+	sc.fieldPat = sc2.fieldPat
+	sc.widths = sc2.widths
+	sc.paragraph = sc2.paragraph
+	sc.paraSepRx = sc2.paraSepRx
+	sc.err = sc2.err
+	if sc.lr != nil {
+		sc.lr = sc.newLineReader(sc.lr)
 	}
-	sc.recNumber = 0
 }
 
+// paragraphSep separates rows in paragraph mode.
+var paragraphSep = regexp.MustCompile(`\n\n+`)
+
 // SetRowSep sets regexp rx that will be used to separate
-// input into rows.
+// input into rows. If rx matches only a literal string, e.g.
+// "\x00" or "--", the rows are separated without using the
+// regexp machinery. If rx is the empty string, the scanner
+// switches to paragraph mode: rows are separated by one or
+// more blank lines, leading and trailing newlines of a source
+// are ignored, and newline separates fields in addition to
+// the field separator.
 func (sc *Scanner) SetRowSep(rx string) {
-	if sc.err != nil || rx == "" {
+	if sc.err != nil {
 		return
 	}
-	rs, err := regexp.Compile(rx)
-	if err != nil {
-		sc.err = fmt.Errorf("setting RS: %v", err)
-		return
+	sc.paragraph = rx == ""
+	if sc.paragraph {
+		sc.rowsRx = paragraphSep
+	} else if lit, ok := literal(rx); ok {
+		sc.rowsRx = nil
+		sc.rowSep = lit
+	} else {
+		rs, err := regexp.Compile(rx)
+		if err != nil {
+			sc.err = fmt.Errorf("setting RS: %v", err)
+			return
+		}
+		sc.rowsRx = rs
 	}
-	sc.rowsRx = rs
 	if sc.lr != nil {
-		sc.lr = newRxLineReader(sc.lr, sc.rowsRx)
+		sc.lr = sc.newLineReader(sc.lr)
+	}
+}
+
+// literal returns the string matched by regexp rx if rx
+// matches only a single literal string.
+func literal(rx string) (lit string, ok bool) {
+	re, err := syntax.Parse(rx, syntax.Perl)
+	if err != nil || re.Op != syntax.OpLiteral || re.Flags&syntax.FoldCase != 0 {
+		return "", false
 	}
+	return string(re.Rune), true
 }
 
 // SetFieldSep sets regexp rx that will be used to separate
-// row into fields.
+// row into fields. A single character is taken literally, even
+// if it is a regexp metacharacter such as "|" or ".".
 func (sc *Scanner) SetFieldSep(rx string) {
 	if sc.err != nil {
 		return
 	}
+	if utf8.RuneCountInString(rx) == 1 {
+		rx = regexp.QuoteMeta(rx)
+	}
 	fs, err := regexp.Compile(rx)
 	if err != nil {
 		sc.err = fmt.Errorf("setting FS: %v", err)
 		return
 	}
 	sc.fieldsRx = fs
+	sc.paraSepRx = nil
+	sc.fieldPat = nil
+	sc.widths = nil
+}
+
+// SetFieldPattern sets regexp rx that will be used to find
+// fields in a row. Unlike SetFieldSep, rx describes what the
+// fields look like rather than what separates them. The leftmost
+// longest match is preferred.
+func (sc *Scanner) SetFieldPattern(rx string) {
+	if sc.err != nil {
+		return
+	}
+	fp, err := regexp.Compile(rx)
+	if err != nil {
+		sc.err = fmt.Errorf("setting FPAT: %v", err)
+		return
+	}
+	fp.Longest()
+	sc.fieldPat = fp
+	sc.widths = nil
+}
+
+// restOfLine is a field width meaning the rest of the row.
+const restOfLine = -1
+
+// SetFieldWidths sets fixed widths that will be used to separate
+// row into fields. widths is a list of positive integers separated
+// by white space, optionally followed by '*' standing for the rest
+// of the row. Widths are counted in runes. Calling SetFieldSep
+// switches back to splitting by a regexp.
+func (sc *Scanner) SetFieldWidths(widths string) {
+	if sc.err != nil {
+		return
+	}
+	list := strings.Fields(widths)
+	ws := make([]int, 0, len(list))
+	for i, f := range list {
+		if f == "*" && i == len(list)-1 {
+			ws = append(ws, restOfLine)
+			continue
+		}
+		n, err := strconv.Atoi(f)
+		if err != nil || n <= 0 {
+			sc.err = fmt.Errorf("setting FIELDWIDTHS: invalid width %q", f)
+			return
+		}
+		ws = append(ws, n)
+	}
+	if len(ws) == 0 {
+		sc.err = errors.New("setting FIELDWIDTHS: no widths")
+		return
+	}
+	sc.widths = ws
+	sc.fieldPat = nil
 }
 
 // Scan scans another record and parses it into fields. It there
@@ -124,32 +271,42 @@ func (sc *Scanner) Scan() bool {
 		return false
 	}
 
-	data, err := ioutil.ReadAll(sc.lr)
-	// line, err := sc.lr.ReadLine()
-	// if err == io.EOF {
-	// 	return false
-	// } else if err == endOfSource {
-	// 	sc.fileRecNumber = 0
-	// 	goto readRecord
-	// } else if err != nil {
-	// 	sc.err = err
-	// 	return false
-	// }
-	fail := len(data) == 0 || (err != nil && err != io.EOF)
-
-	if !fail {
-		sc.splitRecord(data)
-		sc.recNumber++
-		sc.fileRecNumber++
+readRecord:
+	line, err := sc.lr.ReadLine()
+	if err == io.EOF {
+		return false
+	} else if err == endOfSource {
+		sc.fileRecNumber = 0
+		goto readRecord
+	} else if err != nil {
+		sc.err = err
+		return false
 	}
-
-	return !fail
+	rt := sc.lr.Terminator()
+	if sc.paragraph {
+		line = bytes.TrimLeft(line, "\n")
+		if len(line) == 0 {
+			goto readRecord
+		}
+		n := len(line)
+		line = bytes.TrimRight(line, "\n")
+		rt = strings.Repeat("\n", n-len(line)) + rt
+	}
+	sc.rt = rt
+	sc.splitRecord(line)
+	sc.recNumber++
+	sc.fileRecNumber++
+	return true
 }
 
 func (sc *Scanner) splitRecord(rec []byte) {
 	sc.rec = string(rec)
-	if sc.fieldsRx != nil {
-		sc.fields = sc.fieldsRx.Split(sc.rec, -1)
+	if sc.widths != nil {
+		sc.fields = splitWidths(sc.rec, sc.widths)
+	} else if sc.fieldPat != nil {
+		sc.fields = sc.fieldPat.FindAllString(sc.rec, -1)
+	} else if sc.fieldsRx != nil {
+		sc.fields = sc.fieldSepRx().Split(sc.rec, -1)
 		if len(sc.fields) > 0 && sc.fields[0] == "" {
 			sc.fields = sc.fields[1:]
 		}
@@ -161,6 +318,41 @@ func (sc *Scanner) splitRecord(rec []byte) {
 	}
 }
 
+// fieldSepRx returns the regexp used to separate row into
+// fields. It differs from fieldsRx only in paragraph mode.
+func (sc *Scanner) fieldSepRx() *regexp.Regexp {
+	if !sc.paragraph {
+		return sc.fieldsRx
+	}
+	if sc.paraSepRx == nil {
+		sc.paraSepRx = regexp.MustCompile(`(?:` + sc.fieldsRx.String() + `)|\n`)
+	}
+	return sc.paraSepRx
+}
+
+// splitWidths splits s into fields of the given widths. It stops
+// when s is exhausted, so the last field might be shorter.
+func splitWidths(s string, widths []int) []string {
+	fields := make([]string, 0, len(widths))
+	for _, w := range widths {
+		if s == "" {
+			break
+		}
+		if w == restOfLine {
+			fields = append(fields, s)
+			break
+		}
+		i := 0
+		for n := 0; n < w && i < len(s); n++ {
+			_, size := utf8.DecodeRuneInString(s[i:])
+			i += size
+		}
+		fields = append(fields, s[:i])
+		s = s[i:]
+	}
+	return fields
+}
+
 func (sc *Scanner) Err() error {
 	return sc.err
 }
@@ -183,11 +375,37 @@ func (sc *Scanner) Field(i int) string {
 	return ""
 }
 
+// SetField sets the ith field of the current row to s. If i == 0,
+// s becomes the whole record, which is split into fields again.
+// Otherwise, the record is rebuilt by joining the fields with ofs;
+// if i > NF, empty fields are added up to i. SetField panics if i < 0.
+func (sc *Scanner) SetField(i int, s, ofs string) {
+	switch {
+	case i < 0:
+		panic("negative field index")
+	case i == 0:
+		sc.splitRecord([]byte(s))
+		return
+	}
+	for len(sc.fields) < i {
+		sc.fields = append(sc.fields, "")
+	}
+	sc.fields[i-1] = s
+	sc.rec = strings.Join(sc.fields, ofs)
+}
+
 // RecordNumber returns the current record number.
 func (sc *Scanner) RecordNumber() int {
 	return sc.recNumber
 }
 
+// RecordTerminator returns the text that terminated the current
+// record, e.g. the text matched by the row separator. It is empty
+// if the record was terminated by the end of input.
+func (sc *Scanner) RecordTerminator() string {
+	return sc.rt
+}
+
 // FieldCount returns number of fields of the current row.
 func (sc *Scanner) FieldCount() int {
 	return len(sc.fields)
@@ -212,40 +430,79 @@ func (sc *Scanner) FileRecordNumber() int {
 type lineReader interface {
 	Source // to be able to read buffered data
 	ReadLine() ([]byte, error)
+
+	// Terminator returns the text that terminated
+	// the line returned by the last ReadLine call.
+	Terminator() string
 }
 
+// simpleLineReader separates lines using a literal separator,
+// newline by default. Unlike rxLineReader, it doesn't need to
+// search the whole line again for each chunk of input.
 type simpleLineReader struct {
 	src  Source
-	name string // name of the current source
 	br   *bufio.Reader
+	sep  []byte
+	eos  bool // end of source reached after the last line
+	term string
 }
 
-func newSimpleLineReader(src Source) *simpleLineReader {
+func newSimpleLineReader(src Source, sep string) *simpleLineReader {
+	if sep == "" {
+		sep = "\n"
+	}
 	return &simpleLineReader{
-		src:  src,
-		name: src.Name(),
-		br:   bufio.NewReader(src),
+		src: src,
+		br:  bufio.NewReader(src),
+		sep: []byte(sep),
 	}
 }
 
 func (sr *simpleLineReader) Read(p []byte) (n int, err error) {
-	n, err = sr.br.Read(p)
-	if err == endOfSource {
-		sr.name = sr.src.Name()
-	}
-	return
+	return sr.br.Read(p)
 }
 
-func (sr *simpleLineReader) Name() string { return sr.name }
+// Name returns the name of the source being read. Sources are
+// never read ahead past the end of source, so it's the name of
+// the source of the last line.
+func (sr *simpleLineReader) Name() string { return sr.src.Name() }
 
 func (sr *simpleLineReader) ReadLine() ([]byte, error) {
-	line, err := sr.br.ReadBytes('\n')
-	if len(line) > 0 {
-		line = line[:len(line)-1] // remove '\n'
+	if sr.eos {
+		sr.eos = false
+		return nil, endOfSource
+	}
+	sr.term = ""
+	line, err := sr.readLine()
+	switch {
+	case err == nil:
+		sr.term = string(sr.sep)
+		return line[:len(line)-len(sr.sep)], nil
+	case err == endOfSource && len(line) > 0:
+		sr.eos = true
+		return line, nil
+	case err == io.EOF && len(line) > 0:
+		return line, nil
+	}
+	return nil, err
+}
+
+// readLine reads until the first occurrence of the separator,
+// returning a slice containing the data up to and including
+// the separator.
+func (sr *simpleLineReader) readLine() ([]byte, error) {
+	last := sr.sep[len(sr.sep)-1]
+	line, err := sr.br.ReadBytes(last)
+	for err == nil && !bytes.HasSuffix(line, sr.sep) {
+		var b []byte
+		b, err = sr.br.ReadBytes(last)
+		line = append(line, b...)
 	}
 	return line, err
 }
 
+func (sr *simpleLineReader) Terminator() string { return sr.term }
+
 const bufSize = 4096
 
 var _bufSize = bufSize // for testing purposes
@@ -254,9 +511,10 @@ type rxLineReader struct {
 	buf  [bufSize]byte
 	ptr  []byte
 	src  Source
-	name string // name of the current source
 	rx   *regexp.Regexp
 	stat int
+	eos  bool // end of source reached after the last line
+	term string
 }
 
 // stats
@@ -267,9 +525,8 @@ const (
 
 func newRxLineReader(src Source, sepRx *regexp.Regexp) *rxLineReader {
 	return &rxLineReader{
-		src:  src,
-		name: src.Name(),
-		rx:   sepRx,
+		src: src,
+		rx:  sepRx,
 	}
 }
 
@@ -282,15 +539,17 @@ func (rr *rxLineReader) Read(p []byte) (n int, err error) {
 	return rr.src.Read(p)
 }
 
-func (rr *rxLineReader) Name() string {
-	if len(rr.ptr) > 0 {
-		return rr.name
-	}
-	return rr.src.Name()
-}
+func (rr *rxLineReader) Name() string { return rr.src.Name() }
+
+func (rr *rxLineReader) Terminator() string { return rr.term }
 
 func (rr *rxLineReader) ReadLine() (line []byte, err error) {
 	var loc []int
+	rr.term = ""
+	if rr.eos {
+		rr.eos = false
+		return nil, endOfSource
+	}
 	for {
 		if len(rr.ptr) == 0 {
 			if err := rr.loadBuf(); err != nil {
@@ -298,6 +557,7 @@ func (rr *rxLineReader) ReadLine() (line []byte, err error) {
 			}
 			if rr.stat >= sourceEnd && len(rr.ptr) == 0 {
 				if len(line) > 0 && loc != nil {
+					rr.term = string(line[loc[0]:loc[1]])
 					line = line[:loc[0]]
 				}
 				if rr.stat >= finished {
@@ -307,6 +567,10 @@ func (rr *rxLineReader) ReadLine() (line []byte, err error) {
 					return line, io.EOF
 				}
 				rr.stat = 0
+				if len(line) == 0 && loc == nil {
+					return nil, endOfSource
+				}
+				rr.eos = true
 				return line, nil
 			}
 		}
@@ -318,6 +582,7 @@ func (rr *rxLineReader) ReadLine() (line []byte, err error) {
 			continue
 		}
 		rr.ptr = line[loc[1]:]
+		rr.term = string(line[loc[0]:loc[1]])
 		return line[:loc[0]], nil
 	}
 }
@@ -334,7 +599,6 @@ func (rr *rxLineReader) loadBufN(n int) error {
 	case err == io.EOF:
 		rr.stat = finished
 	case err == endOfSource:
-		rr.name = rr.src.Name()
 		rr.stat = sourceEnd
 	case err != nil:
 		return err
@@ -1,10 +1,17 @@
 package scan
 
 import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
 	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestRxReader(t *testing.T) {
@@ -40,7 +47,10 @@ func TestRxReader(t *testing.T) {
 		rr := newRxLineReader(tt.src, rx)
 		for i := 0; ; i++ {
 			line, err := rr.ReadLine()
-			if err == io.EOF {
+			if err == endOfSource {
+				i--
+				continue
+			} else if err == io.EOF {
 				if i != len(tt.lines) {
 					t.Errorf("test[%d]: not enough lines: got %d, want %d",
 						j, i, len(tt.lines))
@@ -89,3 +99,356 @@ func (r earlyEOFReader) Read(p []byte) (n int, err error) {
 	n = copy(p, r.s)
 	return n, io.EOF
 }
+
+func TestFieldWidths(t *testing.T) {
+	tests := []struct {
+		widths string
+		rec    string
+		fields []string
+	}{
+		0: {"2 3", "aabbbcc", []string{"aa", "bbb"}},
+		1: {"2 3 *", "aabbbcc", []string{"aa", "bbb", "cc"}},
+		2: {"2 3 *", "aab", []string{"aa", "b"}},
+		3: {"1 2", "čřžý", []string{"č", "řž"}},
+		4: {"3 *", "", []string{}},
+	}
+
+	for i, tt := range tests {
+		var sc Scanner
+		sc.SetFieldWidths(tt.widths)
+		if err := sc.Err(); err != nil {
+			t.Errorf("test[%d]: unexpected err: %v", i, err)
+			continue
+		}
+		sc.splitRecord([]byte(tt.rec))
+		if got := sc.FieldCount(); got != len(tt.fields) {
+			t.Errorf("test[%d]: got %d fields, want %d", i, got, len(tt.fields))
+			continue
+		}
+		for j, f := range tt.fields {
+			if got := sc.Field(j + 1); got != f {
+				t.Errorf("test[%d]: field %d: got %q, want %q", i, j+1, got, f)
+			}
+		}
+	}
+
+	for _, widths := range []string{"", "2 x", "* 2", "0", "-1"} {
+		var sc Scanner
+		sc.SetFieldWidths(widths)
+		if sc.Err() == nil {
+			t.Errorf("%q: expected an error", widths)
+		}
+	}
+}
+
+func TestFieldPattern(t *testing.T) {
+	tests := []struct {
+		fpat   string
+		rec    string
+		fields []string
+	}{
+		0: {`[^,]*|"[^"]*"`, `a,"b,c",d`, []string{"a", `"b,c"`, "d"}},
+		1: {`[^,]*`, "a,,b", []string{"a", "", "b"}},
+		2: {`\d+`, "no digits", nil},
+	}
+
+	for i, tt := range tests {
+		var sc Scanner
+		sc.SetFieldPattern(tt.fpat)
+		sc.splitRecord([]byte(tt.rec))
+		if got := sc.FieldCount(); got != len(tt.fields) {
+			t.Errorf("test[%d]: got %d fields, want %d", i, got, len(tt.fields))
+			continue
+		}
+		for j, f := range tt.fields {
+			if got := sc.Field(j + 1); got != f {
+				t.Errorf("test[%d]: field %d: got %q, want %q", i, j+1, got, f)
+			}
+		}
+	}
+}
+
+func TestParagraphMode(t *testing.T) {
+	var sc Scanner
+	sc.SetRowSep("")
+	sc.SetFieldSep(",")
+	sc.SetSource(stringSrcs("\n\na,b\nc\n\n\nd\n"))
+
+	want := []struct {
+		rec    string
+		rt     string
+		fields int
+	}{
+		{"a,b\nc", "\n\n\n", 3},
+		{"d", "\n", 1},
+	}
+	for i, w := range want {
+		if !sc.Scan() {
+			t.Fatalf("record %d: unexpected end of input: %v", i, sc.Err())
+		}
+		if got := sc.Field(0); got != w.rec {
+			t.Errorf("record %d: got %q, want %q", i, got, w.rec)
+		}
+		if got := sc.RecordTerminator(); got != w.rt {
+			t.Errorf("record %d: RT: got %q, want %q", i, got, w.rt)
+		}
+		if got := sc.FieldCount(); got != w.fields {
+			t.Errorf("record %d: NF: got %d, want %d", i, got, w.fields)
+		}
+	}
+	if sc.Scan() {
+		t.Errorf("unexpected record %q", sc.Field(0))
+	}
+}
+
+func TestParagraphFieldSep(t *testing.T) {
+	tests := []struct {
+		fs     string
+		fields []string
+	}{
+		{",", []string{"a", "b|c.d", "e"}},
+		{"|", []string{"a,b", "c.d", "e"}},
+		{".", []string{"a,b|c", "d", "e"}},
+		{"[,|]", []string{"a", "b", "c.d", "e"}},
+	}
+	for _, tt := range tests {
+		var sc Scanner
+		sc.SetRowSep("")
+		sc.SetFieldSep(tt.fs)
+		sc.SetSource(stringSrcs("a,b|c.d\ne\n"))
+		if !sc.Scan() {
+			t.Fatalf("FS %q: unexpected end of input: %v", tt.fs, sc.Err())
+		}
+		if got, want := sc.FieldCount(), len(tt.fields); got != want {
+			t.Errorf("FS %q: NF: got %d, want %d", tt.fs, got, want)
+			continue
+		}
+		for i, f := range tt.fields {
+			if got := sc.Field(i + 1); got != f {
+				t.Errorf("FS %q: field %d: got %q, want %q", tt.fs, i+1, got, f)
+			}
+		}
+	}
+}
+
+func TestDecompress(t *testing.T) {
+	const text = "one\ntwo\n"
+
+	var gz, zl bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	gw.Write([]byte(text))
+	gw.Close()
+	zw := zlib.NewWriter(&zl)
+	zw.Write([]byte(text))
+	zw.Close()
+	bz := []byte{
+		66, 90, 104, 57, 49, 65, 89, 38, 83, 89, 167, 20, 43, 119, 0, 0,
+		2, 193, 128, 0, 16, 2, 1, 132, 128, 32, 0, 33, 128, 12, 2, 56,
+		245, 27, 139, 185, 34, 156, 40, 72, 83, 138, 21, 187, 128,
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"gzip", gz.Bytes(), text},
+		{"zlib", zl.Bytes(), text},
+		{"bzip2", bz, text},
+		{"plain", []byte(text), text},
+		{"x^", []byte("x^2\n"), "x^2\n"},
+		{"short", []byte("x"), "x"},
+		{"empty", nil, ""},
+	}
+	for _, tt := range tests {
+		src, err := Decompress(dummySource{bytes.NewReader(tt.data)})
+		if err != nil {
+			t.Errorf("%s: unexpected err: %v", tt.name, err)
+			continue
+		}
+		if src.Name() != "<anonymous>" {
+			t.Errorf("%s: got name %q", tt.name, src.Name())
+		}
+		got, err := ioutil.ReadAll(src)
+		if err != nil {
+			t.Errorf("%s: unexpected err: %v", tt.name, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFollow(t *testing.T) {
+	pollInterval = time.Millisecond
+
+	dir, err := ioutil.TempDir("", "hawk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "log")
+	if err := ioutil.WriteFile(name, []byte("one\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fw, err := Follow(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fw.Close()
+	lr := newSimpleLineReader(fw, "\n")
+	expect := func(want string) {
+		t.Helper()
+		line, err := lr.ReadLine()
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if string(line) != want {
+			t.Fatalf("got %q, want %q", line, want)
+		}
+	}
+
+	expect("one")
+	appendFile(t, name, "two\n")
+	expect("two")
+
+	// Truncation.
+	if err := ioutil.WriteFile(name, []byte("three\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	expect("three")
+
+	// Rotation.
+	if err := os.Rename(name, name+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, name, "four\n")
+	expect("four")
+
+	fw.Stop()
+	if _, err := lr.ReadLine(); err != io.EOF {
+		t.Errorf("got %v, want io.EOF", err)
+	}
+}
+
+func appendFile(t *testing.T, name, s string) {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(s); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLazyMultiSource(t *testing.T) {
+	var opened, closed []string
+	srcs := []string{"a", "b"}
+	ms := LazyMultiSource(func() (Source, error) {
+		if len(srcs) == 0 {
+			return nil, nil
+		}
+		name := srcs[0]
+		srcs = srcs[1:]
+		opened = append(opened, name)
+		return &closeTracker{strings.NewReader(name + "\n"), name, &closed}, nil
+	})
+	if len(opened) != 0 {
+		t.Fatalf("sources opened too early: %v", opened)
+	}
+
+	var sc Scanner
+	sc.SetSource(ms)
+	for i, want := range []string{"a", "b"} {
+		if !sc.Scan() {
+			t.Fatalf("unexpected end of input: %v", sc.Err())
+		}
+		if sc.Filename() != want {
+			t.Errorf("got filename %q, want %q", sc.Filename(), want)
+		}
+		if len(opened) != i+1 {
+			t.Errorf("record %d: opened %v", i, opened)
+		}
+	}
+	if sc.Scan() {
+		t.Fatalf("unexpected record %q", sc.Field(0))
+	}
+	// The sources are closed by whoever opened them.
+	if len(closed) != 0 {
+		t.Errorf("got closed %v, want none", closed)
+	}
+}
+
+type closeTracker struct {
+	io.Reader
+	name   string
+	closed *[]string
+}
+
+func (c *closeTracker) Name() string { return c.name }
+
+func (c *closeTracker) Close() error {
+	*c.closed = append(*c.closed, c.name)
+	return nil
+}
+
+func TestLiteralReader(t *testing.T) {
+	tests := []struct {
+		sep   string
+		src   Source
+		lines []string
+	}{
+		0: {"\x00", stringSrcs("a b\x00c\x00\x00d"), []string{"a b", "c", "", "d"}},
+		1: {"--", stringSrcs("All--work---and-no play--"), []string{"All", "work", "-and-no play"}},
+		2: {"aa", stringSrcs("xaaay"), []string{"x", "ay"}},
+		3: {"--", stringSrcs("AA--B-", "-CC"), []string{"AA", "B-", "-CC"}},
+		4: {strings.Repeat("#", 20), stringSrcs(strings.Repeat("x", 30) + strings.Repeat("#", 20) + "y"),
+			[]string{strings.Repeat("x", 30), "y"}},
+	}
+
+	for j, tt := range tests {
+		sr := newSimpleLineReader(tt.src, tt.sep)
+		var lines []string
+		for {
+			line, err := sr.ReadLine()
+			if err == endOfSource {
+				continue
+			} else if err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("test[%d]: unexpected err: %v", j, err)
+			}
+			lines = append(lines, string(line))
+		}
+		if strings.Join(lines, "|") != strings.Join(tt.lines, "|") {
+			t.Errorf("test[%d]: got %q, want %q", j, lines, tt.lines)
+		}
+	}
+}
+
+func TestLiteral(t *testing.T) {
+	tests := []struct {
+		rx  string
+		lit string
+		ok  bool
+	}{
+		{`\n`, "\n", true},
+		{"\x00", "\x00", true},
+		{`\x00`, "\x00", true},
+		{`--`, "--", true},
+		{`\.`, ".", true},
+		{`.`, "", false},
+		{`\n+`, "", false},
+		{`(?i)ab`, "", false},
+		{`a|b`, "", false},
+	}
+	for _, tt := range tests {
+		lit, ok := literal(tt.rx)
+		if lit != tt.lit || ok != tt.ok {
+			t.Errorf("literal(%q) = %q, %v; want %q, %v", tt.rx, lit, ok, tt.lit, tt.ok)
+		}
+	}
+}
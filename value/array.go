@@ -3,6 +3,8 @@ package value
 import (
 	"bytes"
 	"fmt"
+	"math"
+	"strconv"
 )
 
 // MergeArrays merges two arrays and returns a new array that will
@@ -47,28 +49,59 @@ func NewArray() *Array {
 // Put puts value v under key k into a. If k is nil,
 // autoincrement value is used as a key.
 func (a *Array) Put(k *Scalar, v Value) {
+	var key Scalar
 	if k == nil {
-		k = NewNumber(float64(a.ai))
-		a.keys = append(a.keys, *k)
+		key = *NewInt(int64(a.ai))
+		a.keys = append(a.keys, key)
 		a.ai++
 	} else {
-		if _, ok := a.m[*k]; !ok {
-			a.keys = append(a.keys, *k)
+		key = k.key()
+		if _, ok := a.m[key]; !ok {
+			a.keys = append(a.keys, key)
 			if !a.associative {
-				if k.typ != Number || k.number != float64(a.ai) {
+				if !key.IsInt() || key.int != int64(a.ai) {
 					a.associative = true
 				}
 			}
-			if k.typ == Number && int(k.number) >= a.ai {
-				a.ai = int(k.number) + 1
+			if i, ok := key.index(); ok && i >= a.ai {
+				a.ai = i + 1
 			}
 		}
 	}
-	a.m[*k] = v
+	a.m[key] = v
+}
+
+// Copy returns a deep copy of a.
+func (a *Array) Copy() *Array {
+	z := &Array{
+		ai:          a.ai,
+		associative: a.associative,
+		keys:        append([]Scalar(nil), a.keys...),
+		m:           make(map[Scalar]Value, len(a.m)),
+	}
+	for k, v := range a.m {
+		z.m[k] = Copy(v)
+	}
+	return z
+}
+
+// index returns the integer part of the key k if it is
+// a number or a string that looks like a number, such as
+// a non-integral number converted using CONVFMT.
+func (k Scalar) index() (int, bool) {
+	if k.isInt {
+		return int(k.int), true
+	}
+	if !looksNumeric(k.string) {
+		return 0, false
+	}
+	f, _ := strconv.ParseFloat(k.string, 64)
+	i, ok := floatToInt(math.Trunc(f))
+	return int(i), ok
 }
 
 func (a *Array) Get(k *Scalar) Value {
-	return a.m[*k]
+	return a.m[k.key()]
 }
 
 func (a *Array) Keys() []Scalar {
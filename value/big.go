@@ -0,0 +1,187 @@
+package value
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// NewRat returns a Scalar holding the arbitrary-precision number r.
+// r must not be modified afterwards.
+func NewRat(r *big.Rat) *Scalar {
+	return &Scalar{typ: Number, rat: r}
+}
+
+// Big returns z converted to an arbitrary-precision number. Strings
+// are converted exactly, and floats using their shortest decimal
+// representation, so that both "14.67" and 14.67 become 1467/100.
+// Infinities and NaN remain floats. Arithmetic operations on
+// arbitrary-precision numbers are exact, except for division by zero.
+func (z *Scalar) Big() *Scalar {
+	if z.typ == String {
+		if _, err := strconv.ParseFloat(z.string, 64); err == nil {
+			if r, ok := new(big.Rat).SetString(z.string); ok {
+				return NewRat(r)
+			}
+		}
+	}
+	x := *z
+	if r, ok := x.Number().toRat(); ok {
+		return NewRat(r)
+	}
+	return &x
+}
+
+// IsBig reports whether z is an arbitrary-precision number.
+func (z *Scalar) IsBig() bool {
+	return z.typ == Number && z.rat != nil
+}
+
+// BigFloat returns z as a big.Float with precision prec.
+func (z *Scalar) BigFloat(prec uint) *big.Float {
+	f := new(big.Float).SetPrec(prec)
+	if r, ok := z.num().toRat(); ok {
+		return f.SetRat(r)
+	}
+	return f.SetFloat64(z.number) // infinity; NaN panics, don't use it
+}
+
+// toRat returns the number z as a big.Rat, unless it is
+// an infinity or NaN. The result must not be modified.
+func (z *Scalar) toRat() (*big.Rat, bool) {
+	switch {
+	case z.rat != nil:
+		return z.rat, true
+	case z.isInt:
+		return new(big.Rat).SetInt64(z.int), true
+	case math.IsInf(z.number, 0) || math.IsNaN(z.number):
+		return nil, false
+	}
+	return new(big.Rat).SetString(strconv.FormatFloat(z.number, 'g', -1, 64))
+}
+
+// toRats returns x and y as big.Rats if at least one of them is
+// an arbitrary-precision number and both can be converted.
+func toRats(x, y *Scalar) (*big.Rat, *big.Rat, bool) {
+	if x.rat == nil && y.rat == nil {
+		return nil, nil, false
+	}
+	a, ok := x.num().toRat()
+	b, ok2 := y.num().toRat()
+	return a, b, ok && ok2
+}
+
+// MaxBits is the largest size in bits of the numerator and the
+// denominator of an arbitrary-precision result of Pow or Lsh. The
+// exponent and the shift count are otherwise unbounded, and larger
+// results would take too much memory and time.
+const MaxBits = 1 << 22
+
+// errTooLarge is returned by CheckPow and CheckLsh.
+var errTooLarge = fmt.Errorf("arbitrary-precision result larger than %d bits", MaxBits)
+
+// CheckPow returns an error if x ** y is an arbitrary-precision
+// number larger than MaxBits.
+func CheckPow(x, y *Scalar) error {
+	a, b, ok := toRats(x, y)
+	if !ok || !b.IsInt() || !b.Num().IsInt64() {
+		return nil // not computed exactly
+	}
+	n := new(big.Int).Abs(b.Num())
+	for _, c := range []*big.Int{a.Num(), a.Denom()} {
+		// c**n has at least n*size bits.
+		if size := new(big.Int).Abs(c).BitLen() - 1; size > 0 && n.Cmp(big.NewInt(int64(MaxBits/size))) > 0 {
+			return errTooLarge
+		}
+	}
+	return nil
+}
+
+// CheckLsh returns an error if x << n is an arbitrary-precision
+// number larger than MaxBits.
+func CheckLsh(x *Scalar, n uint) error {
+	if x = x.num(); x.rat == nil {
+		return nil // not arbitrary-precision
+	}
+	if i := ratInt(x.rat); i.Sign() != 0 && uint64(i.BitLen())+uint64(n) > MaxBits {
+		return errTooLarge
+	}
+	return nil
+}
+
+func (z *Scalar) setRat(r *big.Rat) *Scalar {
+	*z = Scalar{typ: Number, rat: r}
+	return z
+}
+
+// ratInt returns the integer part of r.
+func ratInt(r *big.Rat) *big.Int {
+	return new(big.Int).Quo(r.Num(), r.Denom())
+}
+
+// ratString formats r exactly if it has a finite decimal
+// representation, like 14.67. It reports whether it has.
+func ratString(r *big.Rat) (string, bool) {
+	if r.IsInt() {
+		return r.Num().String(), true
+	}
+	// The decimal representation is finite if the denominator
+	// has no prime factors other than 2 and 5.
+	d := new(big.Int).Set(r.Denom())
+	two, five := big.NewInt(2), big.NewInt(5)
+	var m big.Int
+	n2, n5 := 0, 0
+	for m.Rem(d, two).Sign() == 0 {
+		d.Quo(d, two)
+		n2++
+	}
+	for m.Rem(d, five).Sign() == 0 {
+		d.Quo(d, five)
+		n5++
+	}
+	if d.Cmp(big.NewInt(1)) == 0 {
+		digits := n2
+		if n5 > digits {
+			digits = n5
+		}
+		return r.FloatString(digits), true
+	}
+	return "", false
+}
+
+// formatRat is Format for arbitrary-precision numbers.
+func formatRat(s fmt.State, verb rune, r *big.Rat) {
+	switch verb {
+	case 'b', 'd', 'o', 'x', 'X':
+		fmt.Fprintf(s, formatVerb(s, verb), ratInt(r))
+	case 'f', 'F':
+		prec, ok := s.Precision()
+		if !ok {
+			prec = 6
+		}
+		str := r.FloatString(prec)
+		if s.Flag(' ') && r.Sign() >= 0 {
+			str = " " + str
+		}
+		if wid, ok := s.Width(); ok && len(str) < wid {
+			n := wid - len(str)
+			if s.Flag('0') {
+				sign := ""
+				if str[0] == '-' || str[0] == ' ' {
+					sign, str = str[:1], str[1:]
+				}
+				str = sign + strings.Repeat("0", n) + str
+			} else {
+				str = strings.Repeat(" ", n) + str
+			}
+		}
+		fmt.Fprint(s, str)
+	case 'e', 'E', 'g', 'G':
+		f := new(big.Float).SetPrec(1024).SetRat(r)
+		fmt.Fprintf(s, formatVerb(s, verb), f)
+	default:
+		fmt.Fprintf(s, formatVerb(s, verb), ratInt(r).Int64())
+	}
+}
@@ -0,0 +1,118 @@
+package value
+
+import (
+	"math"
+	"math/big"
+)
+
+// The bitwise operations work on the integer parts of numbers. They
+// are done on int64s if the operands are int64s, and on arbitrary-
+// precision integers otherwise. The result is an arbitrary-precision
+// number if one of the operands is; otherwise, it is an int64, or
+// a float if it overflows an int64. Infinities and NaN have no
+// integer part, so the result for them is NaN.
+
+func (z *Scalar) And(x, y *Scalar) *Scalar {
+	return z.bitwise(x, y, (*big.Int).And, func(a, b int64) int64 { return a & b })
+}
+
+func (z *Scalar) Or(x, y *Scalar) *Scalar {
+	return z.bitwise(x, y, (*big.Int).Or, func(a, b int64) int64 { return a | b })
+}
+
+func (z *Scalar) Xor(x, y *Scalar) *Scalar {
+	return z.bitwise(x, y, (*big.Int).Xor, func(a, b int64) int64 { return a ^ b })
+}
+
+func (z *Scalar) AndNot(x, y *Scalar) *Scalar {
+	return z.bitwise(x, y, (*big.Int).AndNot, func(a, b int64) int64 { return a &^ b })
+}
+
+func (z *Scalar) bitwise(x, y *Scalar, bigOp func(z, x, y *big.Int) *big.Int, op func(a, b int64) int64) *Scalar {
+	x, y = x.num(), y.num()
+	if x.isInt && y.isInt {
+		return z.setInt(op(x.int, y.int))
+	}
+	a, ok := x.intPart()
+	b, ok2 := y.intPart()
+	if !ok || !ok2 {
+		return z.setFloat(math.NaN())
+	}
+	return z.setBigInt(bigOp(a, a, b), x.rat != nil || y.rat != nil)
+}
+
+// Not sets z to the bitwise complement of x.
+func (z *Scalar) Not(x *Scalar) *Scalar {
+	if x = x.num(); x.isInt {
+		return z.setInt(^x.int)
+	}
+	a, ok := x.intPart()
+	if !ok {
+		return z.setFloat(math.NaN())
+	}
+	return z.setBigInt(a.Not(a), x.rat != nil)
+}
+
+// maxShift is the largest left shift count of a float
+// operand that is done exactly; larger shifts overflow
+// a float64 anyway.
+const maxShift = 2048
+
+// Lsh sets z to x << n.
+func (z *Scalar) Lsh(x *Scalar, n uint) *Scalar {
+	x = x.num()
+	if a := x.int; x.isInt && n < 63 && a<<n>>n == a {
+		return z.setInt(a << n)
+	}
+	a, ok := x.intPart()
+	if !ok {
+		return z.setFloat(math.NaN())
+	}
+	if x.rat == nil && n > maxShift {
+		return z.setFloat(float64(a.Sign()) * math.Inf(1))
+	}
+	return z.setBigInt(a.Lsh(a, n), x.rat != nil)
+}
+
+// Rsh sets z to x >> n.
+func (z *Scalar) Rsh(x *Scalar, n uint) *Scalar {
+	if x = x.num(); x.isInt {
+		if n > 63 {
+			n = 63
+		}
+		return z.setInt(x.int >> n)
+	}
+	a, ok := x.intPart()
+	if !ok {
+		return z.setFloat(math.NaN())
+	}
+	return z.setBigInt(a.Rsh(a, n), x.rat != nil)
+}
+
+// intPart returns the integer part of the number z as a new
+// big.Int, unless z is an infinity or NaN.
+func (z *Scalar) intPart() (*big.Int, bool) {
+	switch {
+	case z.isInt:
+		return big.NewInt(z.int), true
+	case z.rat != nil:
+		return ratInt(z.rat), true
+	case math.IsInf(z.number, 0) || math.IsNaN(z.number):
+		return nil, false
+	}
+	i, _ := big.NewFloat(z.number).Int(nil)
+	return i, true
+}
+
+// setBigInt sets z to i, as an arbitrary-precision number if exact
+// is set, and as an int64, or a float if i overflows it, otherwise.
+func (z *Scalar) setBigInt(i *big.Int, exact bool) *Scalar {
+	switch {
+	case exact:
+		return z.setRat(new(big.Rat).SetInt(i))
+	case i.IsInt64():
+		return z.setInt(i.Int64())
+	}
+	f, _ := new(big.Float).SetInt(i).Float64()
+	return z.setFloat(f)
+}
@@ -0,0 +1,72 @@
+package value
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// DefaultFormat is the default format used to convert
+// non-integral numbers to strings.
+const DefaultFormat = "%.8g"
+
+// A Conv is a conversion context that determines how numbers are
+// converted to strings, like CONVFMT and OFMT in awk. An empty format
+// means DefaultFormat. Integral numbers are always converted as
+// integers. The zero value is ready to use.
+type Conv struct {
+	CONVFMT string // used for concatenation and array keys
+	OFMT    string // used for output
+}
+
+// String converts v to a string using CONVFMT.
+func (c *Conv) String(v Value) string {
+	return convert(v, c.CONVFMT)
+}
+
+// Output converts v to a string using OFMT.
+func (c *Conv) Output(v Value) string {
+	return convert(v, c.OFMT)
+}
+
+// Key returns the array key for the index z. Non-integral
+// numbers are converted to strings using CONVFMT.
+func (c *Conv) Key(z *Scalar) *Scalar {
+	if z.typ != Number || z.isInt {
+		return z
+	}
+	if k := z.key(); k.isInt {
+		return z
+	}
+	return NewString(z.format(c.CONVFMT))
+}
+
+func convert(v Value, format string) string {
+	if z, ok := v.(*Scalar); ok {
+		return z.format(format)
+	}
+	return v.String()
+}
+
+// format returns z as a string, formatting non-integral
+// numbers using format.
+func (z *Scalar) format(format string) string {
+	if z.typ != Number {
+		return z.String()
+	}
+	if format == "" {
+		format = DefaultFormat
+	}
+	switch {
+	case z.isInt:
+		return strconv.FormatInt(z.int, 10)
+	case z.rat != nil:
+		if s, ok := ratString(z.rat); ok {
+			return s
+		}
+		return fmt.Sprintf(format, z.Float64())
+	case z.number == math.Trunc(z.number) && !math.IsInf(z.number, 0):
+		return strconv.FormatFloat(z.number, 'f', 0, 64)
+	}
+	return fmt.Sprintf(format, z.number)
+}
@@ -0,0 +1,50 @@
+package value
+
+import "fmt"
+
+// A Func is a function value. This package doesn't know how to
+// call it; Impl holds whatever its caller needs to do so.
+type Func struct {
+	name string
+	Impl interface{}
+}
+
+// NewFunc returns a function value named name, or an anonymous
+// one if name is empty.
+func NewFunc(name string, impl interface{}) *Func {
+	return &Func{name: name, Impl: impl}
+}
+
+func (f *Func) Scalar() (z *Scalar, ok bool) { return nil, false }
+func (f *Func) Array() (a *Array, ok bool)   { return nil, false }
+
+// Cmp reports whether f and v are the same function value.
+// Functions cannot be compared using <, >, <= or >=.
+func (f *Func) Cmp(v Value) (cmp int, ok bool) {
+	if f2, ok := v.(*Func); ok && f2 == f {
+		return 0, false
+	}
+	return -1, false
+}
+
+func (f *Func) String() string {
+	if f.name == "" {
+		return "func literal"
+	}
+	return "func " + f.name
+}
+
+func (f *Func) Len() int       { return 0 }
+func (f *Func) Encode() string { return f.String() }
+
+func (f *Func) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		fmt.Fprint(s, f.String())
+		return
+	case 'V':
+		fmt.Fprint(s, "func")
+		return
+	}
+	fmt.Fprintf(s, formatVerb(s, verb), nil)
+}
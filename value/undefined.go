@@ -45,9 +45,13 @@ func (u *Undefined) Cmp(v Value) (cmp int, ok bool) {
 		ok = true
 		switch v.typ {
 		case String:
-			eq = v.string == ""
+			if v.numeric() {
+				eq = v.num().Float64() == 0
+			} else {
+				eq = v.string == ""
+			}
 		case Bool, Number:
-			eq = v.number == 0
+			eq = v.number == 0 && v.int == 0 && (v.rat == nil || v.rat.Sign() == 0)
 		}
 	case *Array:
 		eq = v.Len() == 0
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"math"
+	"math/big"
 	"strconv"
 	"strings"
 )
@@ -53,18 +54,61 @@ type Value interface {
 	Encode() string
 }
 
+// Copy returns a deep copy of v, so that changes to either of
+// them, such as putting values into arrays, don't affect the
+// other one.
+func Copy(v Value) Value {
+	switch v := v.(type) {
+	case *Scalar:
+		z := *v
+		return &z
+	case *Array:
+		return v.Copy()
+	case *Undefined:
+		if v.arr != nil {
+			return v.arr.Copy()
+		}
+		return &Undefined{}
+	}
+	return v
+}
+
+// A Scalar is a string, a boolean or a number. A number is either
+// an integer, which is exact in the whole int64 range, or a float.
+// Arithmetic operations on integers give integers unless they
+// overflow.
+//
+// Strings that come from the input, such as fields, are numeric
+// strings if they look like numbers; see NewStrNum.
 type Scalar struct {
 	typ    ScalarType
 	string string
 	number float64
+	int    int64
+	isInt  bool     // the number is stored in int
+	rat    *big.Rat // arbitrary-precision number, see Big
+	strnum bool     // the string comes from the input
 }
 
 func NewNumber(f float64) *Scalar {
-	return &Scalar{Number, "", f}
+	return &Scalar{typ: Number, number: f}
+}
+
+// NewInt returns a Scalar holding the integer i.
+func NewInt(i int64) *Scalar {
+	return &Scalar{typ: Number, int: i, isInt: true}
 }
 
 func NewString(s string) *Scalar {
-	return &Scalar{String, s, 0}
+	return &Scalar{typ: String, string: s}
+}
+
+// NewStrNum returns a Scalar holding the string s that comes from
+// the input. If s looks like a decimal number, optionally surrounded
+// by blanks, it is a numeric string, which is compared with numbers
+// and other numeric strings as a number.
+func NewStrNum(s string) *Scalar {
+	return &Scalar{typ: String, string: s, strnum: true}
 }
 
 func NewBool(b bool) *Scalar {
@@ -72,7 +116,7 @@ func NewBool(b bool) *Scalar {
 	if b {
 		n = 1
 	}
-	return &Scalar{Bool, "", n}
+	return &Scalar{typ: Bool, number: n}
 }
 
 func (z *Scalar) Scalar() (w *Scalar, ok bool) { return z, true }
@@ -82,48 +126,206 @@ func (z *Scalar) Type() ScalarType {
 	return z.typ
 }
 
+// Cmp compares z and w as numbers if both of them are numeric,
+// that is numbers, booleans or numeric strings, and as strings
+// otherwise.
 func (z *Scalar) Cmp(w Value) (cmp int, ok bool) {
+	if u, ok := w.(*Undefined); ok {
+		cmp, ok := u.Cmp(z)
+		return -cmp, ok
+	}
 	v2, ok := w.Scalar()
 	if !ok {
 		return -1, false
 	}
-	if z.typ == v2.typ {
-		return z.cmp(v2), true
+	if z.numeric() && v2.numeric() {
+		return cmpNumbers(z.num(), v2.num()), true
 	}
-	// TODO: Don't use comparing numbers as a fallback.
-	return z.Number().cmp(v2.Number()), true
+	return strings.Compare(z.String(), v2.String()), true
 }
 
-func (z *Scalar) cmp(b *Scalar) int {
-	switch z.typ {
-	case String:
-		return strings.Compare(z.string, b.string)
-	case Number, Bool:
-		if z.number < b.number {
+// numeric reports whether z is compared as a number.
+func (z *Scalar) numeric() bool {
+	if z.typ == String {
+		return z.strnum && looksNumeric(z.string)
+	}
+	return true
+}
+
+// looksNumeric reports whether s is a decimal integer or float,
+// optionally surrounded by blanks, like 12, -3.5 or 1e6.
+func looksNumeric(s string) bool {
+	s = strings.Trim(s, " \t\n")
+	if s != "" && (s[0] == '+' || s[0] == '-') {
+		s = s[1:]
+	}
+	digits := func() int {
+		n := 0
+		for n < len(s) && s[n] >= '0' && s[n] <= '9' {
+			n++
+		}
+		s = s[n:]
+		return n
+	}
+	n := digits()
+	if s != "" && s[0] == '.' {
+		s = s[1:]
+		n += digits()
+	}
+	if n == 0 {
+		return false
+	}
+	if s != "" && (s[0] == 'e' || s[0] == 'E') {
+		s = s[1:]
+		if s != "" && (s[0] == '+' || s[0] == '-') {
+			s = s[1:]
+		}
+		if digits() == 0 {
+			return false
+		}
+	}
+	return s == ""
+}
+
+// cmpNumbers compares two numbers. An integer and an integral
+// float are compared as integers so that no precision is lost.
+func cmpNumbers(x, y *Scalar) int {
+	if a, b, ok := toRats(x, y); ok {
+		return a.Cmp(b)
+	}
+	a, aok := x.int, x.isInt
+	b, bok := y.int, y.isInt
+	if aok != bok {
+		if !aok {
+			a, aok = floatToInt(x.number)
+		} else {
+			b, bok = floatToInt(y.number)
+		}
+	}
+	if aok && bok {
+		switch {
+		case a < b:
 			return -1
-		} else if z.number > b.number {
+		case a > b:
 			return 1
 		}
 		return 0
 	}
-	panic("unknown scalar type")
+	f, g := x.Float64(), y.Float64()
+	switch {
+	case f < g:
+		return -1
+	case f > g:
+		return 1
+	}
+	return 0
+}
+
+// floatToInt returns f as an integer if it is integral
+// and in the int64 range.
+func floatToInt(f float64) (int64, bool) {
+	if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+		return 0, false
+	}
+	return int64(f), true
 }
 
 func (z *Scalar) Number() *Scalar {
 	switch z.typ {
 	case String:
-		z.number, _ = strconv.ParseFloat(z.string, 64)
+		z.int, z.isInt, z.number = 0, false, 0
+		str := z.string
+		if z.strnum {
+			str = strings.Trim(str, " \t\n")
+		}
+		if i, err := strconv.ParseInt(str, 10, 64); err == nil {
+			z.int, z.isInt = i, true
+		} else if f, err := strconv.ParseFloat(str, 64); err == nil || f != 0 {
+			z.number = f
+		} else {
+			z.isInt = true // not a number, 0
+		}
+	case Bool:
+		z.int, z.isInt = int64(z.number), true
+		z.number = 0
 	}
 	z.typ = Number
 	return z
 }
 
-func (z *Scalar) Float64() float64 { return z.Number().number }
-func (z *Scalar) Int() int         { return int(z.Number().number) }
+// num returns z converted to a number. Unlike Number,
+// it doesn't change z.
+func (z *Scalar) num() *Scalar {
+	if z.typ == Number {
+		return z
+	}
+	x := *z
+	return x.Number()
+}
+
+func (z *Scalar) Float64() float64 {
+	switch n := z.num(); {
+	case n.isInt:
+		return float64(n.int)
+	case n.rat != nil:
+		f, _ := n.rat.Float64()
+		return f
+	default:
+		return n.number
+	}
+}
+
+func (z *Scalar) Int() int { return int(z.Int64()) }
+
+func (z *Scalar) Int64() int64 {
+	switch n := z.num(); {
+	case n.isInt:
+		return n.int
+	case n.rat != nil:
+		if i := ratInt(n.rat); i.IsInt64() {
+			return i.Int64()
+		}
+		return int64(n.Float64())
+	default:
+		return int64(n.number)
+	}
+}
+
+// key returns z in a canonical form, so that equal keys of
+// an Array are equal Go values. Keys are compared as strings,
+// as in awk: numbers and strings holding integers in their
+// canonical form, like 1 and "1", become integers, and all other
+// keys, including non-integral numbers, become strings.
+func (z Scalar) key() Scalar {
+	if z.typ == Number {
+		if z.isInt {
+			return Scalar{typ: Number, int: z.int, isInt: true}
+		}
+		if z.rat != nil && z.rat.IsInt() && z.rat.Num().IsInt64() {
+			return Scalar{typ: Number, int: z.rat.Num().Int64(), isInt: true}
+		}
+		if z.rat == nil {
+			if i, ok := floatToInt(z.number); ok {
+				return Scalar{typ: Number, int: i, isInt: true}
+			}
+		}
+	}
+	s := z.String()
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil && strconv.FormatInt(i, 10) == s {
+		return Scalar{typ: Number, int: i, isInt: true}
+	}
+	return Scalar{typ: String, string: s}
+}
+
+// IsInt reports whether z is a number stored as an integer.
+func (z *Scalar) IsInt() bool {
+	return z.typ == Number && z.isInt
+}
 
+// Bool reports whether z is true, or equal to 1 if it
+// is not a boolean.
 func (z *Scalar) Bool() bool {
-	cmp, _ := z.Cmp(NewBool(true))
-	return cmp == 0
+	return cmpNumbers(z.num(), NewInt(1)) == 0
 }
 
 func (z *Scalar) String() string {
@@ -131,7 +333,7 @@ func (z *Scalar) String() string {
 	case String:
 		return z.string
 	case Number:
-		return fmt.Sprintf("%.8g", z.number)
+		return z.format(DefaultFormat)
 	case Bool:
 		if z.number == 1 {
 			return "true"
@@ -151,6 +353,14 @@ func (z *Scalar) Encode() string {
 }
 
 func (z *Scalar) Format(s fmt.State, verb rune) {
+	if z.IsBig() {
+		switch verb {
+		case 'v', 'V', 't', 's':
+		default:
+			formatRat(s, verb, z.rat)
+			return
+		}
+	}
 	var val interface{}
 	switch verb {
 	case 'v':
@@ -165,7 +375,7 @@ func (z *Scalar) Format(s fmt.State, verb rune) {
 	// Integer:
 	case 'b', 'c', 'd', 'o', 'U':
 		// TODO: %b is different for integer and float.
-		val = z.Int()
+		val = z.Int64()
 	// Floating-point:
 	case 'e', 'E', 'f', 'F', 'g', 'G':
 		val = z.Float64()
@@ -177,7 +387,7 @@ func (z *Scalar) Format(s fmt.State, verb rune) {
 		if z.typ == String {
 			val = z.string
 		} else {
-			val = z.Int()
+			val = z.Int64()
 		}
 	}
 	fmt.Fprintf(s, formatVerb(s, verb), val)
@@ -206,56 +416,156 @@ func (z *Scalar) Len() int {
 }
 
 func (z *Scalar) Add(x, y *Scalar) *Scalar {
+	if a, b, ok := toRats(x, y); ok {
+		return z.setRat(new(big.Rat).Add(a, b))
+	}
+	if a, b, ok := toInt64(x, y); ok {
+		if c := a + b; (c > a) == (b > 0) {
+			return z.setInt(c)
+		}
+	}
 	a, b := toFloat64(x, y)
-	z.typ = Number
-	z.number = a + b
-	return z
+	return z.setFloat(a + b)
 }
 
 func (z *Scalar) Sub(x, y *Scalar) *Scalar {
+	if a, b, ok := toRats(x, y); ok {
+		return z.setRat(new(big.Rat).Sub(a, b))
+	}
+	if a, b, ok := toInt64(x, y); ok {
+		if c := a - b; (c < a) == (b > 0) {
+			return z.setInt(c)
+		}
+	}
 	a, b := toFloat64(x, y)
-	z.typ = Number
-	z.number = a - b
-	return z
+	return z.setFloat(a - b)
 }
 
 func (z *Scalar) Mul(x, y *Scalar) *Scalar {
+	if a, b, ok := toRats(x, y); ok {
+		return z.setRat(new(big.Rat).Mul(a, b))
+	}
+	if a, b, ok := toInt64(x, y); ok {
+		if a == 0 || b == 0 {
+			return z.setInt(0)
+		}
+		c := a * b
+		if c/b == a && !(a == -1 && b == math.MinInt64) && !(b == -1 && a == math.MinInt64) {
+			return z.setInt(c)
+		}
+	}
 	a, b := toFloat64(x, y)
-	z.typ = Number
-	z.number = a * b
-	return z
+	return z.setFloat(a * b)
 }
 
+// Div divides x by y. The result is a float unless one of
+// the operands is an arbitrary-precision number.
 func (z *Scalar) Div(x, y *Scalar) *Scalar {
+	if a, b, ok := toRats(x, y); ok && b.Sign() != 0 {
+		return z.setRat(new(big.Rat).Quo(a, b))
+	}
 	a, b := toFloat64(x, y)
-	z.typ = Number
-	z.number = a / b
-	return z
+	return z.setFloat(a / b)
 }
 
 func (z *Scalar) Mod(x, y *Scalar) *Scalar {
+	if a, b, ok := toRats(x, y); ok && a.IsInt() && b.IsInt() {
+		if b.Sign() == 0 {
+			return z.setFloat(math.NaN())
+		}
+		m := new(big.Int).Rem(a.Num(), b.Num())
+		return z.setRat(new(big.Rat).SetInt(m))
+	}
+	if a, b, ok := toInt64(x, y); ok {
+		switch b {
+		case 0:
+			return z.setFloat(math.NaN())
+		case -1:
+			return z.setInt(0) // avoid overflow of MinInt64 % -1
+		}
+		return z.setInt(a % b)
+	}
 	a, b := toFloat64(x, y)
-	z.typ = Number
 	if int(b) == 0 {
-		z.number = math.NaN()
-	} else {
-		z.number = float64(int(a) % int(b))
+		return z.setFloat(math.NaN())
 	}
-	return z
+	return z.setFloat(float64(int(a) % int(b)))
+}
+
+// Pow sets z to x raised to the power of y. Integral powers of
+// integers and of arbitrary-precision numbers are exact.
+func (z *Scalar) Pow(x, y *Scalar) *Scalar {
+	if a, b, ok := toRats(x, y); ok && b.IsInt() && b.Num().IsInt64() && (a.Sign() != 0 || b.Sign() >= 0) {
+		n := new(big.Int).Abs(b.Num())
+		r := new(big.Rat).SetFrac(
+			new(big.Int).Exp(a.Num(), n, nil),
+			new(big.Int).Exp(a.Denom(), n, nil),
+		)
+		if b.Sign() < 0 {
+			r.Inv(r)
+		}
+		return z.setRat(r)
+	}
+	if a, b, ok := toInt64(x, y); ok && b >= 0 {
+		if c, ok := powInt(a, b); ok {
+			return z.setInt(c)
+		}
+	}
+	a, b := toFloat64(x, y)
+	return z.setFloat(math.Pow(a, b))
+}
+
+// powInt returns a**b for b >= 0. It reports whether
+// the result is in the int64 range.
+func powInt(a, b int64) (int64, bool) {
+	switch {
+	case b == 0:
+		return 1, true
+	case a == 0 || a == 1:
+		return a, true
+	case a == -1:
+		return 1 - 2*(b&1), true
+	case b >= 64:
+		return 0, false
+	}
+	c := new(big.Int).Exp(big.NewInt(a), big.NewInt(b), nil)
+	return c.Int64(), c.IsInt64()
 }
 
 func (z *Scalar) Neg(x *Scalar) *Scalar {
-	z.typ = Number
-	z.number = -x.Float64()
+	x = x.num()
+	if x.rat != nil {
+		return z.setRat(new(big.Rat).Neg(x.rat))
+	}
+	if x.isInt && x.int != math.MinInt64 {
+		return z.setInt(-x.int)
+	}
+	return z.setFloat(-x.Float64())
+}
+
+func (z *Scalar) setInt(i int64) *Scalar {
+	*z = Scalar{typ: Number, int: i, isInt: true}
 	return z
 }
 
+func (z *Scalar) setFloat(f float64) *Scalar {
+	*z = Scalar{typ: Number, number: f}
+	return z
+}
+
+// toInt64 returns x and y as integers if both of them are integers.
+func toInt64(x, y *Scalar) (int64, int64, bool) {
+	if x, y := x.num(), y.num(); x.isInt && y.isInt {
+		return x.int, y.int, true
+	}
+	return 0, 0, false
+}
+
 func toFloat64(x, y *Scalar) (float64, float64) {
 	return x.Float64(), y.Float64()
 }
 
 func (z *Scalar) Concat(x, y *Scalar) *Scalar {
-	z.typ = String
-	z.string = x.String() + y.String()
+	*z = Scalar{typ: String, string: x.String() + y.String()}
 	return z
 }
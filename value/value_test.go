@@ -0,0 +1,171 @@
+package value
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestCmp(t *testing.T) {
+	var (
+		str    = NewString
+		strnum = NewStrNum
+		num    = NewNumber
+		i      = NewInt
+		undef  = &Undefined{}
+	)
+	tests := []struct {
+		x, y Value
+		cmp  int
+	}{
+		// Numbers are compared as numbers.
+		0: {i(2), i(10), -1},
+		1: {i(3), num(3), 0},
+		2: {num(2.5), i(2), 1},
+		3: {NewBool(true), i(1), 0},
+
+		// Strings are compared as strings, even with numbers.
+		4: {str("abc"), i(0), 1},
+		5: {str("10"), i(9), -1},
+		6: {str("10"), str("9"), -1},
+		7: {str("3.0"), i(3), 1},
+		8: {str(""), i(0), -1},
+
+		// Numeric strings are compared as numbers with numbers
+		// and other numeric strings.
+		9:  {strnum("10"), i(9), 1},
+		10: {strnum("3.0"), i(3), 0},
+		11: {strnum(" 12 "), i(12), 0},
+		12: {strnum("1e3"), num(1000), 0},
+		13: {strnum("+5"), strnum("5.0"), 0},
+		14: {strnum("-.5"), num(-0.5), 0},
+		15: {strnum("10"), strnum("9"), 1},
+		16: {strnum("9007199254740993"), i(9007199254740992), 1},
+
+		// But as strings with strings.
+		17: {strnum("10"), str("9"), -1},
+		18: {strnum("3.0"), str("3"), 1},
+
+		// Strings that don't look like numbers are strings.
+		19: {strnum("abc"), i(0), 1},
+		20: {strnum(""), i(0), -1},
+		21: {strnum("12abc"), i(12), 1},
+		22: {strnum("0x1A"), i(26), -1},
+		23: {strnum("1e"), i(1), 1},
+		24: {strnum("."), str("."), 0},
+		25: {strnum("inf"), num(1), 1},
+
+		// Undefined values equal both "" and 0.
+		26: {str(""), undef, 0},
+		27: {i(0), undef, 0},
+		28: {strnum("0"), undef, 0},
+		29: {str("0"), undef, 1},
+	}
+	for i, tt := range tests {
+		cmp, ok := tt.x.Cmp(tt.y)
+		if !ok {
+			t.Errorf("test %d: %v and %v are not comparable", i, tt.x, tt.y)
+			continue
+		}
+		if cmp != tt.cmp {
+			t.Errorf("test %d: Cmp(%v, %v) = %d, want %d", i, tt.x, tt.y, cmp, tt.cmp)
+		}
+		if cmp, _ := tt.y.Cmp(tt.x); cmp != -tt.cmp {
+			t.Errorf("test %d: Cmp(%v, %v) = %d, want %d", i, tt.y, tt.x, cmp, -tt.cmp)
+		}
+	}
+}
+
+func TestKeepsType(t *testing.T) {
+	x, y := NewStrNum("012"), NewString("abc")
+	x.Cmp(NewInt(12))
+	y.Bool()
+	new(Scalar).Add(x, y)
+	new(Scalar).Neg(y)
+	y.Float64()
+	if x.Type() != String || x.String() != "012" {
+		t.Errorf("x changed to %V %v", x, x)
+	}
+	if y.Type() != String || y.String() != "abc" {
+		t.Errorf("y changed to %V %v", y, y)
+	}
+}
+
+func TestArrayKeys(t *testing.T) {
+	tests := []struct {
+		put, get *Scalar
+		same     bool
+	}{
+		0:  {NewInt(1), NewString("1"), true},
+		1:  {NewStrNum("1"), NewInt(1), true},
+		2:  {NewNumber(2), NewStrNum("2"), true},
+		3:  {NewBool(true), NewString("true"), true},
+		4:  {NewBool(true), NewInt(1), false},
+		5:  {NewNumber(0.5), NewString("0.5"), true},
+		6:  {NewString("01"), NewInt(1), false},
+		7:  {NewStrNum(" 1"), NewInt(1), false},
+		8:  {NewString("1.0"), NewInt(1), false},
+		9:  {NewNumber(1e20), NewString("100000000000000000000"), true},
+		10: {NewString("-0"), NewInt(0), false},
+	}
+	for i, tt := range tests {
+		a := NewArray()
+		a.Put(tt.put, NewString("v"))
+		if got := a.Get(tt.get) != nil; got != tt.same {
+			t.Errorf("test %d: a[%v] found after putting a[%v]: %v, want %v",
+				i, tt.get.Encode(), tt.put.Encode(), got, tt.same)
+		}
+	}
+}
+
+func TestArrayAutoincrement(t *testing.T) {
+	a := NewArray()
+	a.Put(nil, NewString("a"))
+	a.Put(NewString("1"), NewString("b"))
+	a.Put(nil, NewString("c"))
+	if a.associative {
+		t.Errorf("array with keys %v is associative", a.Keys())
+	}
+	a.Put(NewString("7.5"), NewString("d"))
+	a.Put(nil, NewString("e"))
+	if !a.associative {
+		t.Errorf("array with keys %v is not associative", a.Keys())
+	}
+	if want := `[0: "a", 1: "b", 2: "c", "7.5": "d", 8: "e"]`; a.String() != want {
+		t.Errorf("got %s, want %s", a, want)
+	}
+}
+
+func TestCheckSize(t *testing.T) {
+	rat := func(a, b int64) *Scalar { return NewRat(big.NewRat(a, b)) }
+	tests := []struct {
+		x, y   *Scalar
+		lsh    bool
+		tooBig bool
+	}{
+		0: {rat(2, 1), NewInt(MaxBits), false, false},
+		1: {rat(2, 1), NewInt(MaxBits + 1), false, true},
+		2: {rat(3, 1), NewInt(1e9), false, true},
+		3: {rat(1, 3), NewInt(-1e9), false, true},
+		4: {rat(1, 1), NewInt(1e15), false, false},
+		5: {rat(-1, 1), NewInt(1e15 + 1), false, false},
+		6: {rat(0, 1), NewInt(1e15), false, false},
+		7: {rat(2, 1), NewNumber(0.5), false, false},
+		8: {NewInt(2), NewInt(1e9), false, false}, // not arbitrary-precision
+
+		9:  {rat(1, 1), NewInt(MaxBits - 1), true, false},
+		10: {rat(1, 1), NewInt(MaxBits), true, true},
+		11: {rat(0, 1), NewInt(1e12), true, false},
+		12: {NewInt(1), NewInt(1e12), true, false}, // not arbitrary-precision
+	}
+	for i, tt := range tests {
+		var err error
+		if tt.lsh {
+			err = CheckLsh(tt.x, uint(tt.y.Int64()))
+		} else {
+			err = CheckPow(tt.x, tt.y)
+		}
+		if (err != nil) != tt.tooBig {
+			t.Errorf("%d: got err %v, want too big: %v", i, err, tt.tooBig)
+		}
+	}
+}